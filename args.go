@@ -0,0 +1,95 @@
+package main
+
+import (
+	"strings"
+)
+
+// buildArgs returns the scanner args of the configuration. file holds the
+// content of sonar-project.properties when Config.UsingProperties is set,
+// and props the parsed Config.Properties, which override the other args.
+func buildArgs(c Config, file, props map[string]string) []string {
+	p := Plugin{Config: c}
+	args := []string{
+		"-Dsonar.host.url=" + c.Host,
+	}
+
+	argsParameter := []string{
+		"-Dsonar.projectKey=" + strings.Replace(c.Key, "/", ":", -1),
+		"-Dsonar.projectName=" + c.Name,
+		"-Dsonar.projectVersion=" + c.Version,
+		"-Dsonar.sources=" + c.Sources,
+		"-Dsonar.ws.timeout=" + c.Timeout,
+		"-Dsonar.inclusions=" + c.Inclusions,
+		"-Dsonar.exclusions=" + c.Exclusions,
+		"-Dsonar.log.level=" + c.Level,
+		"-Dsonar.showProfiling=" + c.ShowProfiling,
+		"-Dsonar.scm.provider=git",
+	}
+	if c.UsingProperties {
+		argsParameter = withoutFileProperties(argsParameter, file)
+		argsParameter = withoutArgs(argsParameter, p.defaultProperties())
+	}
+	if p.buildTool() {
+		argsParameter = withoutArgs(argsParameter, buildToolProperties)
+	}
+	if c.GoTests != "" && !p.buildTool() {
+		argsParameter = goTestArgs(argsParameter, c.Sources, file)
+	}
+	args = append(args, argsParameter...)
+
+	if pullRequest, _ := p.pullRequestAnalysis(); pullRequest {
+		args = append(args, p.pullRequestArgs()...)
+	} else if c.BranchAnalysis {
+		args = append(args, "-Dsonar.branch.name="+c.Branch)
+	}
+
+	if c.GoCoverage != "" {
+		args = append(args, "-Dsonar.coverageReportPaths="+goCoverageReport)
+	}
+	if c.GoTests != "" {
+		args = append(args, "-Dsonar.testExecutionReportPaths="+goTestsReport)
+	}
+	if c.LintReports != "" {
+		args = append(args, "-Dsonar.externalIssuesReportPaths="+lintIssuesReport)
+	}
+	if c.Mode == modeDotnet && c.DotnetOpenCover != "" {
+		args = append(args, "-Dsonar.cs.opencover.reportsPaths="+c.DotnetOpenCover)
+	}
+	if c.Mode == modeDotnet && c.DotnetCobertura != "" {
+		args = append(args, "-Dsonar.coverageReportPaths="+dotnetCoverageReport)
+	}
+
+	return mergeProperties(args, props)
+}
+
+// goTestPattern matches the Go test files.
+const goTestPattern = "**/*_test.go"
+
+// goTestArgs declares the Go test files as tests, as SonarQube rejects a
+// test execution report on main files. The test files are excluded from the
+// sources and the sources also searched for tests, unless the
+// sonar-project.properties file sets them or defines modules.
+func goTestArgs(params []string, sources string, file map[string]string) []string {
+	if _, ok := file["sonar.modules"]; ok {
+		return params
+	}
+	for i, arg := range params {
+		if argKey(arg) == "sonar.exclusions" {
+			if exclusions := strings.SplitN(arg, "=", 2)[1]; exclusions != "" {
+				params[i] = arg + "," + goTestPattern
+			} else {
+				params[i] = arg + goTestPattern
+			}
+		}
+	}
+	if s, ok := file["sonar.sources"]; ok {
+		sources = s
+	}
+	if _, ok := file["sonar.tests"]; !ok {
+		params = append(params, "-Dsonar.tests="+sources)
+	}
+	if _, ok := file["sonar.test.inclusions"]; !ok {
+		params = append(params, "-Dsonar.test.inclusions="+goTestPattern)
+	}
+	return params
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildArgs(t *testing.T) {
+	base := Config{
+		Key:           "octocat/hello-world",
+		Name:          "octocat/hello-world",
+		Host:          "http://localhost:9000",
+		Token:         "secret",
+		Version:       "42",
+		Branch:        "feature",
+		Sources:       ".",
+		Timeout:       "60",
+		Exclusions:    "**/vendor/**",
+		Level:         "INFO",
+		ShowProfiling: "false",
+	}
+	defaults := []string{
+		"-Dsonar.host.url=http://localhost:9000",
+		"-Dsonar.projectKey=octocat:hello-world",
+		"-Dsonar.projectName=octocat/hello-world",
+		"-Dsonar.projectVersion=42",
+		"-Dsonar.sources=.",
+		"-Dsonar.ws.timeout=60",
+		"-Dsonar.inclusions=",
+		"-Dsonar.exclusions=**/vendor/**",
+		"-Dsonar.log.level=INFO",
+		"-Dsonar.showProfiling=false",
+		"-Dsonar.scm.provider=git",
+	}
+	pullRequest := func(c *Config) {
+		c.Event = "pull_request"
+		c.PullRequest = "7"
+		c.SourceBranch = "feature"
+		c.TargetBranch = "master"
+	}
+	concat := func(lists ...[]string) []string {
+		var args []string
+		for _, l := range lists {
+			args = append(args, l...)
+		}
+		return args
+	}
+
+	tests := []struct {
+		name   string
+		config func(*Config)
+		file   map[string]string
+		props  map[string]string
+		want   []string
+	}{
+		{
+			name:   "defaults",
+			config: func(c *Config) {},
+			want:   defaults,
+		},
+		{
+			name:   "branch analysis",
+			config: func(c *Config) { c.BranchAnalysis = true },
+			want:   concat(defaults, []string{"-Dsonar.branch.name=feature"}),
+		},
+		{
+			name:   "pull request analysis on pull_request event",
+			config: pullRequest,
+			want: concat(defaults, []string{
+				"-Dsonar.pullrequest.key=7",
+				"-Dsonar.pullrequest.branch=feature",
+				"-Dsonar.pullrequest.base=master",
+			}),
+		},
+		{
+			name: "pull request analysis wins over branch analysis",
+			config: func(c *Config) {
+				pullRequest(c)
+				c.BranchAnalysis = true
+			},
+			want: concat(defaults, []string{
+				"-Dsonar.pullrequest.key=7",
+				"-Dsonar.pullrequest.branch=feature",
+				"-Dsonar.pullrequest.base=master",
+			}),
+		},
+		{
+			name: "pull request analysis forced off",
+			config: func(c *Config) {
+				pullRequest(c)
+				c.PullRequestAnalysis = "false"
+				c.BranchAnalysis = true
+			},
+			want: concat(defaults, []string{"-Dsonar.branch.name=feature"}),
+		},
+		{
+			name: "pull request analysis on push event",
+			config: func(c *Config) {
+				pullRequest(c)
+				c.Event = "push"
+			},
+			want: defaults,
+		},
+		{
+			name:   "using properties without file",
+			config: func(c *Config) { c.UsingProperties = true },
+			file:   map[string]string{},
+			want: []string{
+				"-Dsonar.host.url=http://localhost:9000",
+				"-Dsonar.projectKey=octocat:hello-world",
+				"-Dsonar.projectName=octocat/hello-world",
+				"-Dsonar.projectVersion=42",
+				"-Dsonar.sources=.",
+				"-Dsonar.inclusions=",
+				"-Dsonar.exclusions=**/vendor/**",
+				"-Dsonar.scm.provider=git",
+			},
+		},
+		{
+			name:   "using properties with project description",
+			config: func(c *Config) { c.UsingProperties = true },
+			file: map[string]string{
+				"sonar.projectKey":     "my:project",
+				"sonar.sources":        "src",
+				"sonar.projectVersion": "1.0",
+			},
+			want: []string{
+				"-Dsonar.host.url=http://localhost:9000",
+				"-Dsonar.projectName=octocat/hello-world",
+				"-Dsonar.projectVersion=42",
+				"-Dsonar.inclusions=",
+				"-Dsonar.exclusions=**/vendor/**",
+				"-Dsonar.scm.provider=git",
+			},
+		},
+		{
+			name: "using properties with explicit build settings",
+			config: func(c *Config) {
+				c.UsingProperties = true
+				c.Level = "DEBUG"
+				c.Origins = map[string]string{"level": "env PLUGIN_LEVEL", "timeout": "default"}
+			},
+			file: map[string]string{
+				"sonar.log.level":  "TRACE",
+				"sonar.ws.timeout": "120",
+			},
+			want: []string{
+				"-Dsonar.host.url=http://localhost:9000",
+				"-Dsonar.projectKey=octocat:hello-world",
+				"-Dsonar.projectName=octocat/hello-world",
+				"-Dsonar.projectVersion=42",
+				"-Dsonar.sources=.",
+				"-Dsonar.inclusions=",
+				"-Dsonar.exclusions=**/vendor/**",
+				"-Dsonar.log.level=DEBUG",
+				"-Dsonar.scm.provider=git",
+			},
+		},
+		{
+			name: "using properties with modules and branch analysis",
+			config: func(c *Config) {
+				c.UsingProperties = true
+				c.BranchAnalysis = true
+			},
+			file: map[string]string{
+				"sonar.projectKey": "my:project",
+				"sonar.modules":    "api,web",
+			},
+			want: []string{
+				"-Dsonar.host.url=http://localhost:9000",
+				"-Dsonar.projectName=octocat/hello-world",
+				"-Dsonar.projectVersion=42",
+				"-Dsonar.scm.provider=git",
+				"-Dsonar.branch.name=feature",
+			},
+		},
+		{
+			name:   "properties override generated args",
+			config: func(c *Config) {},
+			props: map[string]string{
+				"sonar.projectVersion": "1.2.3",
+				"sonar.sourceEncoding": "UTF-8",
+			},
+			want: []string{
+				"-Dsonar.host.url=http://localhost:9000",
+				"-Dsonar.projectKey=octocat:hello-world",
+				"-Dsonar.projectName=octocat/hello-world",
+				"-Dsonar.projectVersion=1.2.3",
+				"-Dsonar.sources=.",
+				"-Dsonar.ws.timeout=60",
+				"-Dsonar.inclusions=",
+				"-Dsonar.exclusions=**/vendor/**",
+				"-Dsonar.log.level=INFO",
+				"-Dsonar.showProfiling=false",
+				"-Dsonar.scm.provider=git",
+				"-Dsonar.sourceEncoding=UTF-8",
+			},
+		},
+		{
+			name: "properties override the file",
+			config: func(c *Config) {
+				c.UsingProperties = true
+			},
+			file:  map[string]string{"sonar.projectKey": "my:project"},
+			props: map[string]string{"sonar.projectKey": "other:project"},
+			want: []string{
+				"-Dsonar.host.url=http://localhost:9000",
+				"-Dsonar.projectName=octocat/hello-world",
+				"-Dsonar.projectVersion=42",
+				"-Dsonar.sources=.",
+				"-Dsonar.inclusions=",
+				"-Dsonar.exclusions=**/vendor/**",
+				"-Dsonar.scm.provider=git",
+				"-Dsonar.projectKey=other:project",
+			},
+		},
+		{
+			name: "go reports",
+			config: func(c *Config) {
+				c.GoCoverage = "coverage.out"
+				c.GoTests = "tests.json"
+				c.LintReports = "lint.json"
+			},
+			want: []string{
+				"-Dsonar.host.url=http://localhost:9000",
+				"-Dsonar.projectKey=octocat:hello-world",
+				"-Dsonar.projectName=octocat/hello-world",
+				"-Dsonar.projectVersion=42",
+				"-Dsonar.sources=.",
+				"-Dsonar.ws.timeout=60",
+				"-Dsonar.inclusions=",
+				"-Dsonar.exclusions=**/vendor/**,**/*_test.go",
+				"-Dsonar.log.level=INFO",
+				"-Dsonar.showProfiling=false",
+				"-Dsonar.scm.provider=git",
+				"-Dsonar.tests=.",
+				"-Dsonar.test.inclusions=**/*_test.go",
+				"-Dsonar.coverageReportPaths=" + goCoverageReport,
+				"-Dsonar.testExecutionReportPaths=" + goTestsReport,
+				"-Dsonar.externalIssuesReportPaths=" + lintIssuesReport,
+			},
+		},
+		{
+			name: "go tests with test files set by the file and properties",
+			config: func(c *Config) {
+				c.GoTests = "tests.json"
+				c.UsingProperties = true
+				c.Exclusions = ""
+			},
+			file: map[string]string{
+				"sonar.sources": "src",
+				"sonar.tests":   "src",
+			},
+			props: map[string]string{"sonar.test.inclusions": "src/**/*_test.go"},
+			want: []string{
+				"-Dsonar.host.url=http://localhost:9000",
+				"-Dsonar.projectKey=octocat:hello-world",
+				"-Dsonar.projectName=octocat/hello-world",
+				"-Dsonar.projectVersion=42",
+				"-Dsonar.inclusions=",
+				"-Dsonar.exclusions=**/*_test.go",
+				"-Dsonar.scm.provider=git",
+				"-Dsonar.test.inclusions=src/**/*_test.go",
+				"-Dsonar.testExecutionReportPaths=" + goTestsReport,
+			},
+		},
+		{
+			name:   "maven mode",
+			config: func(c *Config) { c.Mode = modeMaven },
+			want: []string{
+				"-Dsonar.host.url=http://localhost:9000",
+				"-Dsonar.projectKey=octocat:hello-world",
+				"-Dsonar.projectName=octocat/hello-world",
+				"-Dsonar.projectVersion=42",
+				"-Dsonar.ws.timeout=60",
+				"-Dsonar.inclusions=",
+				"-Dsonar.exclusions=**/vendor/**",
+				"-Dsonar.log.level=INFO",
+				"-Dsonar.showProfiling=false",
+				"-Dsonar.scm.provider=git",
+			},
+		},
+		{
+			name: "dotnet mode with coverage",
+			config: func(c *Config) {
+				c.Mode = modeDotnet
+				c.DotnetOpenCover = "**/coverage.opencover.xml"
+				c.DotnetCobertura = "**/coverage.cobertura.xml"
+			},
+			want: []string{
+				"-Dsonar.host.url=http://localhost:9000",
+				"-Dsonar.projectKey=octocat:hello-world",
+				"-Dsonar.projectName=octocat/hello-world",
+				"-Dsonar.projectVersion=42",
+				"-Dsonar.ws.timeout=60",
+				"-Dsonar.inclusions=",
+				"-Dsonar.exclusions=**/vendor/**",
+				"-Dsonar.log.level=INFO",
+				"-Dsonar.showProfiling=false",
+				"-Dsonar.scm.provider=git",
+				"-Dsonar.cs.opencover.reportsPaths=**/coverage.opencover.xml",
+				"-Dsonar.coverageReportPaths=" + dotnetCoverageReport,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := base
+			test.config(&c)
+			got := buildArgs(c, test.file, test.props)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got args\n%q\nwant\n%q", got, test.want)
+			}
+			for _, arg := range got {
+				if argKey(arg) == "sonar.login" || argKey(arg) == "sonar.token" {
+					t.Errorf("token passed as argument: %s", arg)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// cardSchema is the adaptive card template used to render the card data.
+const cardSchema = "https://raw.githubusercontent.com/aosapps/drone-sonar-plugin/master/card.json"
+
+type (
+	// Card is the step card rendered by Drone from DRONE_CARD_PATH.
+	Card struct {
+		Schema string   `json:"schema"`
+		Data   CardData `json:"data"`
+	}
+
+	// CardData is the data bound to the adaptive card template.
+	CardData struct {
+		ProjectKey     string       `json:"projectKey"`
+		QualityGate    string       `json:"qualityGate"`
+		DashboardURL   string       `json:"dashboardUrl"`
+		Metrics        []CardMetric `json:"metrics"`
+		NewCodeMetrics []CardMetric `json:"newCodeMetrics"`
+	}
+
+	// CardMetric is a measure displayed on the card.
+	CardMetric struct {
+		Label string `json:"label"`
+		Value string `json:"value"`
+	}
+)
+
+// newCodeMetrics are the measures of the new code period.
+var newCodeMetrics = []Metric{
+	{"new_violations", "New Issues"},
+	{"new_bugs", "New Bugs"},
+	{"new_vulnerabilities", "New Vulnerabilities"},
+	{"new_code_smells", "New Code Smells"},
+	{"new_coverage", "Coverage on New Code"},
+	{"new_duplicated_lines_density", "Duplication on New Code"},
+}
+
+// card builds the step card of the analysis summary.
+func (p Plugin) card(report *Report) *Card {
+	return &Card{
+		Schema: cardSchema,
+		Data: CardData{
+			ProjectKey:     report.ProjectKey,
+			QualityGate:    report.QualityGate,
+			DashboardURL:   p.dashboardURL(report.ProjectKey),
+			Metrics:        cardMetrics(reportMetrics, report.Measures),
+			NewCodeMetrics: cardMetrics(newCodeMetrics, report.Measures),
+		},
+	}
+}
+
+// dashboardURL returns the link to the project dashboard on Config.Host.
+func (p Plugin) dashboardURL(projectKey string) string {
+	query := url.Values{"id": {projectKey}}
+	if pullRequest, _ := p.pullRequestAnalysis(); pullRequest {
+		query.Set("pullRequest", p.Config.PullRequest)
+	} else if p.Config.BranchAnalysis {
+		query.Set("branch", p.Config.Branch)
+	}
+	return strings.TrimRight(p.Config.Host, "/") + "/dashboard?" + query.Encode()
+}
+
+func cardMetrics(metrics []Metric, measures map[string]string) []CardMetric {
+	values := make([]CardMetric, len(metrics))
+	for i, m := range metrics {
+		values[i] = CardMetric{Label: m.Label, Value: formatMeasure(m.Key, measures[m.Key])}
+	}
+	return values
+}
+
+// writeCard writes the card to the path given by Drone.
+func writeCard(path string, card *Card) error {
+	data, err := json.Marshal(card)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/aosapps/drone-sonar-plugin/scm"
+)
+
+// commentMarker identifies the comment of the plugin on a pull request.
+const commentMarker = "<!-- drone-sonar-plugin -->"
+
+// commentMetrics are the measures of the pull request comment.
+var commentMetrics = []Metric{
+	{"new_violations", "New Issues"},
+	{"new_bugs", "New Bugs"},
+	{"new_vulnerabilities", "New Vulnerabilities"},
+	{"new_code_smells", "New Code Smells"},
+	{"new_coverage", "Coverage on New Code"},
+	{"coverage", "Overall Coverage"},
+}
+
+// comment posts the analysis summary on the pull request, or updates the
+// comment posted by a previous build.
+func (p Plugin) comment(ctx context.Context, report *Report) error {
+	pullRequest, err := strconv.Atoi(p.Config.PullRequest)
+	if err != nil {
+		return fmt.Errorf("invalid pull request number %q", p.Config.PullRequest)
+	}
+	client, err := scm.New(p.Config.ScmProvider, p.Config.ScmServer, p.Config.ScmToken, p.Config.Repo)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("==> Commenting pull request #%d\n", pullRequest)
+	return client.Comment(ctx, pullRequest, commentMarker, string(p.commentBody(report)))
+}
+
+func (p Plugin) commentBody(report *Report) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s\n", commentMarker)
+	fmt.Fprintf(&buf, "### SonarQube Quality Gate: %s %s\n\n", gateIcon(report.QualityGate), report.QualityGate)
+	fmt.Fprintf(&buf, "| Metric | Value |\n|---|---|\n")
+	for _, m := range commentMetrics {
+		fmt.Fprintf(&buf, "| %s | %s |\n", m.Label, formatMeasure(m.Key, report.Measures[m.Key]))
+	}
+	fmt.Fprintf(&buf, "\n[See analysis details on SonarQube](%s)\n", p.dashboardURL(report.ProjectKey))
+	return buf.Bytes()
+}
+
+func gateIcon(status string) string {
+	switch status {
+	case "OK":
+		return ":white_check_mark:"
+	case "ERROR":
+		return ":x:"
+	case "WARN":
+		return ":warning:"
+	}
+	return ":grey_question:"
+}
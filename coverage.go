@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// reportDir is the directory of the reports generated for the scanner.
+const reportDir = ".drone-sonar"
+
+// Reports generated for the scanner.
+var (
+	goCoverageReport     = filepath.Join(reportDir, "coverage.xml")
+	goTestsReport        = filepath.Join(reportDir, "tests.xml")
+	lintIssuesReport     = filepath.Join(reportDir, "issues.json")
+	dotnetCoverageReport = filepath.Join(reportDir, "dotnet-coverage.xml")
+)
+
+type (
+	// coverageXML is the SonarQube Generic Test Coverage report.
+	coverageXML struct {
+		XMLName xml.Name          `xml:"coverage"`
+		Version int               `xml:"version,attr"`
+		Files   []coverageFileXML `xml:"file"`
+	}
+
+	coverageFileXML struct {
+		Path  string            `xml:"path,attr"`
+		Lines []coverageLineXML `xml:"lineToCover"`
+	}
+
+	coverageLineXML struct {
+		LineNumber int  `xml:"lineNumber,attr"`
+		Covered    bool `xml:"covered,attr"`
+	}
+
+	// modules maps Go module paths to their directory in the workspace.
+	modules map[string]string
+)
+
+// goCoverage converts the coverprofiles of Config.GoCoverage to
+// goCoverageReport.
+func (p Plugin) goCoverage() error {
+	profiles, err := expandPaths(p.Config.GoCoverage)
+	if err != nil {
+		return err
+	}
+	mods, err := findModules(".")
+	if err != nil {
+		return err
+	}
+	return convertCoverage(profiles, mods, goCoverageReport)
+}
+
+// findModules walks root for go.mod files, skipping vendor directories.
+func findModules(root string) (modules, error) {
+	mods := modules{}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			name := info.Name()
+			if path != root && (name == "vendor" || strings.HasPrefix(name, ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Name() != "go.mod" {
+			return nil
+		}
+		module, err := readModulePath(path)
+		if err != nil {
+			return err
+		}
+		if module != "" {
+			mods[module] = filepath.Dir(path)
+		}
+		return nil
+	})
+	return mods, err
+}
+
+// readModulePath returns the module path declared in a go.mod file.
+func readModulePath(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], "\"`"), nil
+		}
+	}
+	return "", scanner.Err()
+}
+
+// resolve maps an import path of a Go file to its path in the workspace,
+// using the longest matching module path.
+func (m modules) resolve(file string) (string, bool) {
+	best := ""
+	for module := range m {
+		if (file == module || strings.HasPrefix(file, module+"/")) && len(module) > len(best) {
+			best = module
+		}
+	}
+	if best != "" {
+		return filepath.Join(m[best], strings.TrimPrefix(file, best)), true
+	}
+	if _, err := os.Stat(file); err == nil {
+		return filepath.Clean(file), true
+	}
+	return "", false
+}
+
+// expandPaths splits a comma-separated list of paths and expands globs.
+func expandPaths(list string) ([]string, error) {
+	var paths []string
+	for _, pattern := range strings.Split(list, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no file matches %s", pattern)
+		}
+		paths = append(paths, matches...)
+	}
+	return paths, nil
+}
+
+// convertCoverage converts Go coverprofiles to a Generic Test Coverage
+// report. A line is covered when any block containing it was executed.
+func convertCoverage(profiles []string, mods modules, output string) error {
+	lines := map[string]map[int]bool{}
+	for _, profile := range profiles {
+		if err := readCoverProfile(profile, mods, lines); err != nil {
+			return err
+		}
+	}
+
+	return writeCoverage(lines, output)
+}
+
+// writeCoverage writes the covered state of the lines of each file as a
+// Generic Test Coverage report.
+func writeCoverage(lines map[string]map[int]bool, output string) error {
+	report := coverageXML{Version: 1}
+	for _, file := range sortedKeys(lines) {
+		numbers := make([]int, 0, len(lines[file]))
+		for n := range lines[file] {
+			numbers = append(numbers, n)
+		}
+		sort.Ints(numbers)
+
+		f := coverageFileXML{Path: filepath.ToSlash(file)}
+		for _, n := range numbers {
+			f.Lines = append(f.Lines, coverageLineXML{LineNumber: n, Covered: lines[file][n]})
+		}
+		report.Files = append(report.Files, f)
+	}
+	return writeXML(output, report)
+}
+
+// readCoverProfile adds the lines of a coverprofile to lines.
+func readCoverProfile(profile string, mods modules, lines map[string]map[int]bool) error {
+	f, err := os.Open(profile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	unresolved := map[string]bool{}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "mode:") {
+			continue
+		}
+
+		// name.go:line.column,line.column numberOfStatements count
+		i := strings.LastIndex(line, ":")
+		fields := strings.Fields(line[i+1:])
+		if i < 0 || len(fields) != 3 {
+			return fmt.Errorf("%s:%d: invalid coverprofile line", profile, n)
+		}
+		start, end, err := blockLines(fields[0])
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", profile, n, err)
+		}
+		count, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", profile, n, err)
+		}
+
+		file, ok := mods.resolve(line[:i])
+		if !ok {
+			if !unresolved[line[:i]] {
+				fmt.Printf("==> Skipping coverage of %s: file not found\n", line[:i])
+				unresolved[line[:i]] = true
+			}
+			continue
+		}
+		if lines[file] == nil {
+			lines[file] = map[int]bool{}
+		}
+		for l := start; l <= end; l++ {
+			lines[file][l] = lines[file][l] || count > 0
+		}
+	}
+	return scanner.Err()
+}
+
+// blockLines parses the start and end lines of a "l.c,l.c" block.
+func blockLines(block string) (int, int, error) {
+	parts := strings.Split(block, ",")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid block %s", block)
+	}
+	start, err := strconv.Atoi(strings.SplitN(parts[0], ".", 2)[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := strconv.Atoi(strings.SplitN(parts[1], ".", 2)[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
+
+// writeXML writes v as an indented XML document.
+func writeXML(path string, v interface{}) error {
+	data, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append([]byte(xml.Header), data...), 0644)
+}
+
+func sortedKeys(m map[string]map[int]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFiles creates the files, by path, in the current directory.
+func writeFiles(t *testing.T, files map[string]string) {
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// multiModule is a workspace with a root module, a nested module, a vendored
+// module and a module in a hidden directory.
+var multiModule = map[string]string{
+	"go.mod":                     "module example.com/app\n\ngo 1.13\n",
+	"main.go":                    "package main\n",
+	"tools/go.mod":               "// tools\nmodule \"example.com/app/tools\"\n",
+	"tools/gen/gen.go":           "package gen\n",
+	"libs/lib/go.mod":            "module example.com/lib\n",
+	"libs/lib/lib.go":            "package lib\n",
+	"vendor/github.com/x/go.mod": "module github.com/x\n",
+	".cache/go.mod":              "module example.com/cache\n",
+	"legacy.go":                  "package main\n",
+}
+
+func TestFindModules(t *testing.T) {
+	chdir(t)
+	writeFiles(t, multiModule)
+
+	got, err := findModules(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := modules{
+		"example.com/app":       ".",
+		"example.com/app/tools": "tools",
+		"example.com/lib":       filepath.Join("libs", "lib"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestModulesResolve(t *testing.T) {
+	chdir(t)
+	writeFiles(t, multiModule)
+	mods := modules{
+		"example.com/app":       ".",
+		"example.com/app/tools": "tools",
+		"example.com/lib":       filepath.Join("libs", "lib"),
+	}
+
+	tests := []struct {
+		file string
+		want string
+		ok   bool
+	}{
+		{"example.com/app/main.go", "main.go", true},
+		{"example.com/app/tools/gen/gen.go", filepath.Join("tools", "gen", "gen.go"), true},
+		{"example.com/lib/lib.go", filepath.Join("libs", "lib", "lib.go"), true},
+		{"example.com/application/main.go", "", false},
+		{"github.com/x/x.go", "", false},
+		{"./legacy.go", "legacy.go", true},
+		{"missing.go", "", false},
+	}
+	for _, test := range tests {
+		got, ok := mods.resolve(test.file)
+		if got != test.want || ok != test.ok {
+			t.Errorf("%s: got %q, %v, want %q, %v", test.file, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestReadCoverProfile(t *testing.T) {
+	chdir(t)
+	writeFiles(t, multiModule)
+	writeFiles(t, map[string]string{
+		"cover.out": strings.Join([]string{
+			"mode: set",
+			"example.com/app/main.go:3.13,6.2 2 0",
+			"example.com/app/main.go:4.10,4.20 1 1",
+			"example.com/app/main.go:8.1,9.2 1 1",
+			"example.com/app/main.go:9.3,10.2 1 0",
+			"",
+			"example.com/app/tools/gen/gen.go:5.1,5.9 1 3",
+			"github.com/x/x.go:1.1,2.2 1 1",
+			"github.com/x/x.go:3.1,4.2 1 1",
+		}, "\n"),
+	})
+
+	lines := map[string]map[int]bool{}
+	var err error
+	out := captureStdout(t, func() {
+		err = readCoverProfile("cover.out", modules{
+			"example.com/app":       ".",
+			"example.com/app/tools": "tools",
+		}, lines)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]map[int]bool{
+		"main.go":                               {3: false, 4: true, 5: false, 6: false, 8: true, 9: true, 10: false},
+		filepath.Join("tools", "gen", "gen.go"): {5: true},
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("got %v, want %v", lines, want)
+	}
+	if want := "==> Skipping coverage of github.com/x/x.go: file not found\n"; out != want {
+		t.Errorf("got output %q, want %q", out, want)
+	}
+}
+
+func TestReadCoverProfileInvalid(t *testing.T) {
+	tests := []struct {
+		line string
+		err  string
+	}{
+		{"main.go 3.1,5.2 1 0", "cover.out:2: invalid coverprofile line"},
+		{"main.go:3.1,5.2 1", "cover.out:2: invalid coverprofile line"},
+		{"main.go:3.1-5.2 1 0", "cover.out:2: invalid block 3.1-5.2"},
+		{"main.go:x.1,5.2 1 0", `cover.out:2: strconv.Atoi: parsing "x": invalid syntax`},
+		{"main.go:3.1,y.2 1 0", `cover.out:2: strconv.Atoi: parsing "y": invalid syntax`},
+		{"main.go:3.1,5.2 1 many", `cover.out:2: strconv.Atoi: parsing "many": invalid syntax`},
+	}
+	for _, test := range tests {
+		t.Run(test.line, func(t *testing.T) {
+			chdir(t)
+			writeFiles(t, map[string]string{
+				"main.go":   "package main\n",
+				"cover.out": "mode: set\n" + test.line + "\n",
+			})
+
+			err := readCoverProfile("cover.out", modules{}, map[string]map[int]bool{})
+			if err == nil || err.Error() != test.err {
+				t.Errorf("got error %v, want %s", err, test.err)
+			}
+		})
+	}
+}
+
+func TestConvertCoverage(t *testing.T) {
+	chdir(t)
+	writeFiles(t, multiModule)
+	writeFiles(t, map[string]string{
+		"unit.out":        "mode: count\nexample.com/app/main.go:3.1,4.2 1 0\nexample.com/lib/lib.go:2.1,2.9 1 0\n",
+		"integration.out": "mode: count\nexample.com/app/main.go:4.1,5.2 1 2\n",
+	})
+	mods, err := findModules(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := filepath.Join(reportDir, "coverage.xml")
+	if err := convertCoverage([]string{"unit.out", "integration.out"}, mods, output); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<coverage version="1">
+  <file path="libs/lib/lib.go">
+    <lineToCover lineNumber="2" covered="false"></lineToCover>
+  </file>
+  <file path="main.go">
+    <lineToCover lineNumber="3" covered="false"></lineToCover>
+    <lineToCover lineNumber="4" covered="true"></lineToCover>
+    <lineToCover lineNumber="5" covered="true"></lineToCover>
+  </file>
+</coverage>`
+	if string(got) != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const modeDotnet = "dotnet"
+
+// dotnetReportTaskFile is written by the end step of SonarScanner for .NET.
+var dotnetReportTaskFile = filepath.Join(".sonarqube", "out", ".sonar", "report-task.txt")
+
+// coberturaXML is a Cobertura coverage report.
+type coberturaXML struct {
+	Sources  []string `xml:"sources>source"`
+	Packages []struct {
+		Classes []struct {
+			Filename string `xml:"filename,attr"`
+			Lines    []struct {
+				Number int `xml:"number,attr"`
+				Hits   int `xml:"hits,attr"`
+			} `xml:"lines>line"`
+		} `xml:"classes>class"`
+	} `xml:"packages>package"`
+}
+
+// dotnet runs the begin, build, test and end steps of SonarScanner for .NET.
+// When the build fails the analysis is not ended, since it would publish
+// the results of a partial build. When the tests fail the analysis is still
+// ended, then the test failure is returned.
+func (p Plugin) dotnet(ctx context.Context, args, env []string) error {
+	begin := append([]string{"sonarscanner", "begin"}, dotnetArgs(args)...)
+	if err := p.runRetry(ctx, "dotnet", begin, env); err != nil {
+		return fmt.Errorf("dotnet sonarscanner begin: %w", err)
+	}
+
+	// only the scanner steps get the credentials, not the build scripts
+	if err := p.run(ctx, "sh", []string{"-c", p.Config.DotnetBuild}, nil); err != nil {
+		return fmt.Errorf("build failed, analysis not published: %w", err)
+	}
+
+	var testErr error
+	if p.Config.DotnetTest != "" {
+		testErr = p.run(ctx, "sh", []string{"-c", p.Config.DotnetTest}, nil)
+		if errors.Is(testErr, errCancelled) || errors.Is(testErr, errTimedOut) {
+			return testErr
+		}
+		if testErr != nil {
+			fmt.Printf("==> Tests failed, ending the analysis\n")
+		}
+	}
+	if p.Config.DotnetCobertura != "" {
+		if err := convertCobertura(p.Config.DotnetCobertura, dotnetCoverageReport); err != nil {
+			return err
+		}
+	}
+
+	if err := p.runRetry(ctx, "dotnet", []string{"sonarscanner", "end"}, env); err != nil {
+		return fmt.Errorf("dotnet sonarscanner end: %w", err)
+	}
+	if testErr != nil {
+		return fmt.Errorf("tests failed: %w", testErr)
+	}
+	return nil
+}
+
+// dotnetArgs converts scanner args to SonarScanner for .NET parameters. The
+// project key, name and version have their own parameters.
+func dotnetArgs(args []string) []string {
+	params := make([]string, 0, len(args))
+	for _, arg := range args {
+		value := strings.SplitN(arg, "=", 2)[1]
+		switch argKey(arg) {
+		case "sonar.projectKey":
+			params = append(params, "/k:"+value)
+		case "sonar.projectName":
+			params = append(params, "/n:"+value)
+		case "sonar.projectVersion":
+			params = append(params, "/v:"+value)
+		default:
+			params = append(params, "/d:"+strings.TrimPrefix(arg, "-D"))
+		}
+	}
+	return params
+}
+
+// convertCobertura converts Cobertura reports to a Generic Test Coverage
+// report, resolving file names against the sources of each report.
+func convertCobertura(list, output string) error {
+	reports, err := expandPaths(list)
+	if err != nil {
+		return err
+	}
+
+	lines := map[string]map[int]bool{}
+	for _, path := range reports {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var report coberturaXML
+		if err := xml.Unmarshal(data, &report); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		for _, pkg := range report.Packages {
+			for _, class := range pkg.Classes {
+				file := resolveSource(report.Sources, class.Filename)
+				if lines[file] == nil {
+					lines[file] = map[int]bool{}
+				}
+				for _, l := range class.Lines {
+					lines[file][l.Number] = lines[file][l.Number] || l.Hits > 0
+				}
+			}
+		}
+	}
+	return writeCoverage(lines, output)
+}
+
+// resolveSource returns the first existing path of file under the sources.
+func resolveSource(sources []string, file string) string {
+	for _, source := range sources {
+		path := filepath.Join(strings.TrimSpace(source), file)
+		if _, err := os.Stat(path); err == nil {
+			if wd, err := os.Getwd(); err == nil {
+				if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+					return rel
+				}
+			}
+			return path
+		}
+	}
+	return file
+}
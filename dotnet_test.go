@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveSource(t *testing.T) {
+	dir := chdir(t)
+	writeFiles(t, map[string]string{
+		"src/App/Program.cs": "class Program {}",
+		"lib/Util.cs":        "class Util {}",
+	})
+	outside := tempDir(t)
+	if err := ioutil.WriteFile(filepath.Join(outside, "Shared.cs"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		sources []string
+		file    string
+		want    string
+	}{
+		{"absolute source", []string{filepath.Join(dir, "src")}, "App/Program.cs", filepath.Join("src", "App", "Program.cs")},
+		{"first existing source", []string{filepath.Join(dir, "lib"), " " + filepath.Join(dir, "src") + " "}, "App/Program.cs", filepath.Join("src", "App", "Program.cs")},
+		{"relative source", []string{"lib"}, "Util.cs", filepath.Join("lib", "Util.cs")},
+		{"outside the workspace", []string{outside}, "Shared.cs", filepath.Join(outside, "Shared.cs")},
+		{"not found", []string{filepath.Join(dir, "src")}, "Missing.cs", "Missing.cs"},
+		{"without sources", nil, "lib/Util.cs", "lib/Util.cs"},
+	}
+	for _, test := range tests {
+		if got := resolveSource(test.sources, test.file); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+// coberturaReport returns a Cobertura report of the classes, by file name,
+// with their line hits.
+func coberturaReport(sources []string, classes map[string]map[int]int) string {
+	report := `<?xml version="1.0" encoding="utf-8"?>` + "\n<coverage>\n  <sources>\n"
+	for _, source := range sources {
+		report += "    <source>" + source + "</source>\n"
+	}
+	report += "  </sources>\n  <packages>\n    <package name=\"App\">\n      <classes>\n"
+	for file, lines := range classes {
+		report += fmt.Sprintf("        <class name=\"C\" filename=\"%s\">\n          <lines>\n", file)
+		for number, hits := range lines {
+			report += fmt.Sprintf("            <line number=\"%d\" hits=\"%d\" />\n", number, hits)
+		}
+		report += "          </lines>\n        </class>\n"
+	}
+	return report + "      </classes>\n    </package>\n  </packages>\n</coverage>\n"
+}
+
+func TestConvertCobertura(t *testing.T) {
+	dir := chdir(t)
+	src, lib := filepath.Join(dir, "src"), filepath.Join(dir, "lib")
+	writeFiles(t, map[string]string{
+		"src/App/Program.cs": "class Program {}",
+		"lib/Util.cs":        "class Util {}",
+		"tests/App.Tests/TestResults/a1/coverage.cobertura.xml": coberturaReport([]string{src}, map[string]map[int]int{
+			"App/Program.cs": {1: 0, 2: 3},
+		}),
+		"tests/Util.Tests/TestResults/b2/coverage.cobertura.xml": coberturaReport([]string{lib, src}, map[string]map[int]int{
+			"App/Program.cs": {1: 1, 3: 0},
+			"Util.cs":        {5: 0},
+		}),
+	})
+
+	output := filepath.Join(reportDir, "dotnet-coverage.xml")
+	if err := convertCobertura("tests/*/TestResults/*/coverage.cobertura.xml", output); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<coverage version="1">
+  <file path="lib/Util.cs">
+    <lineToCover lineNumber="5" covered="false"></lineToCover>
+  </file>
+  <file path="src/App/Program.cs">
+    <lineToCover lineNumber="1" covered="true"></lineToCover>
+    <lineToCover lineNumber="2" covered="true"></lineToCover>
+    <lineToCover lineNumber="3" covered="false"></lineToCover>
+  </file>
+</coverage>`
+	if string(got) != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestConvertCoberturaInvalid(t *testing.T) {
+	chdir(t)
+	writeFiles(t, map[string]string{"coverage.cobertura.xml": "<coverage><packages>"})
+
+	err := convertCobertura("coverage.cobertura.xml", filepath.Join(reportDir, "dotnet-coverage.xml"))
+	if want := "coverage.cobertura.xml: XML syntax error on line 1: unexpected EOF"; err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+	if err := convertCobertura("missing/*.xml", "out.xml"); err == nil || err.Error() != "no file matches missing/*.xml" {
+		t.Errorf("got error %v, want no file matches missing/*.xml", err)
+	}
+}
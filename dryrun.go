@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// propertySettings are the settings each scanner property is built from.
+var propertySettings = map[string]string{
+	"sonar.host.url":                  "host",
+	"sonar.projectKey":                "key",
+	"sonar.projectName":               "name",
+	"sonar.projectVersion":            "ver",
+	"sonar.sources":                   "sources",
+	"sonar.ws.timeout":                "timeout",
+	"sonar.inclusions":                "inclusions",
+	"sonar.exclusions":                "exclusions",
+	"sonar.log.level":                 "level",
+	"sonar.showProfiling":             "showProfiling",
+	"sonar.branch.name":               "branch",
+	"sonar.pullrequest.key":           "pullRequest",
+	"sonar.pullrequest.branch":        "sourceBranch",
+	"sonar.pullrequest.base":          "targetBranch",
+	"sonar.coverageReportPaths":       "goCoverage",
+	"sonar.testExecutionReportPaths":  "goTests",
+	"sonar.tests":                     "goTests",
+	"sonar.test.inclusions":           "goTests",
+	"sonar.externalIssuesReportPaths": "lintReports",
+	"sonar.cs.opencover.reportsPaths": "dotnetOpenCover",
+}
+
+// dryRun prints the commands of the analysis and the scanner properties
+// with the origin of their value, without running anything.
+func (p Plugin) dryRun(ctx context.Context, file, props map[string]string, args []string, tokenProperties []string) error {
+	commands, err := p.commands(ctx, args)
+	if err != nil {
+		return err
+	}
+
+	out := newRedactWriter(os.Stdout, p.Config.Token)
+	defer out.Flush()
+
+	fmt.Fprintf(out, "==> Dry run, the analysis would run:\n")
+	for _, command := range commands {
+		fmt.Fprintf(out, "    %s\n", shellJoin(command))
+	}
+
+	fmt.Fprintf(out, "==> Scanner properties:\n")
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "    PROPERTY\tVALUE\tSOURCE\n")
+	for _, row := range p.propertyOrigins(file, props, args, tokenProperties) {
+		fmt.Fprintf(w, "    %s\t%s\t%s\n", row[0], row[1], row[2])
+	}
+	return w.Flush()
+}
+
+// commands returns the commands run by the analysis in the configured mode,
+// without downloading the scanner.
+func (p Plugin) commands(ctx context.Context, args []string) ([][]string, error) {
+	if p.Config.Mode == modeDotnet {
+		commands := [][]string{
+			append([]string{"dotnet", "sonarscanner", "begin"}, dotnetArgs(args)...),
+			{"sh", "-c", p.Config.DotnetBuild},
+		}
+		if p.Config.DotnetTest != "" {
+			commands = append(commands, []string{"sh", "-c", p.Config.DotnetTest})
+		}
+		return append(commands, []string{"dotnet", "sonarscanner", "end"}), nil
+	}
+	if (p.Config.Mode == "" || p.Config.Mode == modeCLI) && p.Config.ScannerVersion != "" {
+		return [][]string{append([]string{p.scannerBin()}, args...)}, nil
+	}
+	name, cmdArgs, err := p.scannerCommand(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return [][]string{append([]string{name}, cmdArgs...)}, nil
+}
+
+// propertyOrigins returns the key, value and origin of every scanner
+// property, sorted by key. The token is redacted.
+func (p Plugin) propertyOrigins(file, props map[string]string, args []string, tokenProperties []string) [][3]string {
+	origins := map[string][2]string{}
+	for k, v := range file {
+		origins[k] = [2]string{v, "file " + projectPropertiesFile}
+	}
+	for _, arg := range args {
+		key := argKey(arg)
+		value := strings.SplitN(arg, "=", 2)[1]
+		switch _, ok := props[key]; {
+		case ok:
+			origins[key] = [2]string{value, p.origin("properties")}
+		case key == "sonar.coverageReportPaths" && p.Config.Mode == modeDotnet:
+			origins[key] = [2]string{value, p.origin("dotnetCobertura")}
+		default:
+			origins[key] = [2]string{value, p.origin(propertySettings[key])}
+		}
+	}
+	for _, property := range tokenProperties {
+		origins[property] = [2]string{"*****", p.origin("token")}
+	}
+
+	keys := make([]string, 0, len(origins))
+	for k := range origins {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	rows := make([][3]string, len(keys))
+	for i, k := range keys {
+		value := origins[k][0]
+		lower := strings.ToLower(k)
+		if strings.Contains(lower, "password") || strings.Contains(lower, "token") || strings.Contains(lower, "secret") {
+			value = "*****"
+		}
+		rows[i] = [3]string{k, value, origins[k][1]}
+	}
+	return rows
+}
+
+// origin returns where the setting comes from, the default value when
+// unknown.
+func (p Plugin) origin(setting string) string {
+	if origin, ok := p.Config.Origins[setting]; ok {
+		return origin
+	}
+	return "default"
+}
+
+// shellJoin quotes the command for a POSIX shell.
+func shellJoin(command []string) string {
+	quoted := make([]string, len(command))
+	for i, arg := range command {
+		if arg == "" || strings.ContainsAny(arg, " \t\n'\"\\$`*?[]{}()<>|&;#~!") {
+			arg = "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/codegangsta/cli"
+)
+
+func TestExecDryRun(t *testing.T) {
+	chdir(t)
+	runner := &fakeRunner{}
+	p := Plugin{Config: testConfig(t), Runner: runner}
+	p.Config.DryRun = true
+	p.Config.QualityGate = true
+	p.Config.GoCoverage = "coverage.out"
+	p.Config.Properties = "sonar.exclusions=vendor/**"
+	p.Config.Origins = map[string]string{
+		"host":       "env PLUGIN_SONAR_HOST",
+		"token":      "env PLUGIN_SONAR_TOKEN",
+		"key":        "env DRONE_REPO",
+		"goCoverage": "flag --goCoverage",
+		"properties": "env PLUGIN_PROPERTIES",
+	}
+
+	output := captureStdout(t, func() {
+		if err := p.Exec(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	if len(runner.calls) != 0 {
+		t.Errorf("got %d commands run, want none", len(runner.calls))
+	}
+	if _, err := os.Stat(goCoverageReport); !os.IsNotExist(err) {
+		t.Errorf("coverage report converted: %v", err)
+	}
+	if strings.Contains(output, p.Config.Token) {
+		t.Errorf("token not redacted:\n%s", output)
+	}
+	for _, want := range []string{
+		"    sonar-scanner -Dsonar.host.url=" + p.Config.Host + " -Dsonar.projectKey=octocat:hello-world ",
+		"-Dsonar.coverageReportPaths=.drone-sonar/coverage.xml",
+		"'-Dsonar.exclusions=vendor/**'",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("missing %q in:\n%s", want, output)
+		}
+	}
+	for _, want := range [][]string{
+		{"sonar.coverageReportPaths", ".drone-sonar/coverage.xml", "flag --goCoverage"},
+		{"sonar.exclusions", "vendor/**", "env PLUGIN_PROPERTIES"},
+		{"sonar.host.url", p.Config.Host, "env PLUGIN_SONAR_HOST"},
+		{"sonar.login", "*****", "env PLUGIN_SONAR_TOKEN"},
+		{"sonar.projectKey", "octocat:hello-world", "env DRONE_REPO"},
+		{"sonar.projectVersion", "42", "default"},
+	} {
+		if !containsRow(output, want) {
+			t.Errorf("missing row %q in:\n%s", want, output)
+		}
+	}
+}
+
+// containsRow reports whether a line of output has the fields of row.
+func containsRow(output string, row []string) bool {
+	for _, line := range strings.Split(output, "\n") {
+		if reflect.DeepEqual(strings.Fields(line), strings.Fields(strings.Join(row, " "))) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPropertyOrigins(t *testing.T) {
+	p := Plugin{Config: Config{
+		Key:             "octocat/hello-world",
+		Version:         "42",
+		Mode:            modeDotnet,
+		DotnetCobertura: "coverage.cobertura.xml",
+		UsingProperties: true,
+		Origins: map[string]string{
+			"ver":             "env DRONE_BUILD_NUMBER",
+			"dotnetCobertura": "env PLUGIN_DOTNETCOBERTURA",
+		},
+	}}
+	file := map[string]string{"sonar.projectKey": "hello", "sonar.sources": "src"}
+	props := map[string]string{"sonar.projectVersion": "1.0"}
+	args := buildArgs(p.Config, file, props)
+
+	got := map[string][3]string{}
+	for _, row := range p.propertyOrigins(file, props, args, []string{"sonar.token"}) {
+		got[row[0]] = row
+	}
+	want := map[string][3]string{
+		"sonar.projectKey":          {"sonar.projectKey", "hello", "file sonar-project.properties"},
+		"sonar.sources":             {"sonar.sources", "src", "file sonar-project.properties"},
+		"sonar.projectVersion":      {"sonar.projectVersion", "1.0", "default"},
+		"sonar.coverageReportPaths": {"sonar.coverageReportPaths", dotnetCoverageReport, "env PLUGIN_DOTNETCOBERTURA"},
+		"sonar.token":               {"sonar.token", "*****", "default"},
+		"sonar.scm.provider":        {"sonar.scm.provider", "git", "default"},
+	}
+	for k, row := range want {
+		if got[k] != row {
+			t.Errorf("got %q, want %q", got[k], row)
+		}
+	}
+}
+
+func TestDryRunCommands(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   [][]string
+	}{
+		{
+			name:   "cli",
+			config: Config{},
+			want:   [][]string{{"sonar-scanner", "-Dsonar.host.url="}},
+		},
+		{
+			name:   "scanner version",
+			config: Config{ScannerVersion: "4.6.2.2472", ScannerCache: "/cache"},
+			want:   [][]string{{"/cache/sonar-scanner-4.6.2.2472/bin/sonar-scanner", "-Dsonar.host.url="}},
+		},
+		{
+			name:   "gradle",
+			config: Config{Mode: modeGradle},
+			want:   [][]string{{"gradle", "sonar", "-Dsonar.host.url="}},
+		},
+		{
+			name:   "dotnet",
+			config: Config{Mode: modeDotnet, DotnetBuild: "dotnet build", DotnetTest: "dotnet test"},
+			want: [][]string{
+				{"dotnet", "sonarscanner", "begin", "/d:sonar.host.url="},
+				{"sh", "-c", "dotnet build"},
+				{"sh", "-c", "dotnet test"},
+				{"dotnet", "sonarscanner", "end"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chdir(t)
+			p := Plugin{Config: test.config}
+			got, err := p.commands(context.Background(), []string{"-Dsonar.host.url="})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestShellJoin(t *testing.T) {
+	got := shellJoin([]string{"sh", "-c", "dotnet build", "-Dsonar.exclusions=**/*_test.go", "it's", ""})
+	want := `sh -c 'dotnet build' '-Dsonar.exclusions=**/*_test.go' 'it'\''s' ''`
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestOrigins(t *testing.T) {
+	setenv(t, "PLUGIN_SONAR_HOST", "https://sonar.example.com")
+	setenv(t, "PLUGIN_SONAR_TOKEN", "")
+
+	var got map[string]string
+	app := cli.NewApp()
+	app.Writer = ioutil.Discard
+	app.Flags = []cli.Flag{
+		cli.StringFlag{Name: "host", EnvVar: "PLUGIN_SONAR_HOST"},
+		cli.StringFlag{Name: "token", EnvVar: "PLUGIN_SONAR_TOKEN"},
+		cli.StringFlag{Name: "key", EnvVar: "PLUGIN_SONAR_KEY"},
+		cli.BoolFlag{Name: "dryRun", EnvVar: "PLUGIN_DRY_RUN"},
+	}
+	app.Action = func(c *cli.Context) {
+		got = origins(c)
+	}
+	if err := app.Run([]string{"drone-sonar", "--key", "hello", "--dryRun"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"host":   "env PLUGIN_SONAR_HOST",
+		"token":  "default",
+		"key":    "flag --key",
+		"dryRun": "flag --dryRun",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
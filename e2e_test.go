@@ -0,0 +1,334 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aosapps/drone-sonar-plugin/sonartest"
+)
+
+// sonarConfig returns a configuration analysing octocat:hello-world on
+// the fake server, scanned by the fake scanner run in-process.
+func sonarConfig(t *testing.T, server *sonartest.Server) Plugin {
+	config := testConfig(t)
+	config.Host = server.URL
+	config.QualityGateTimeout = "10"
+	runner := &fakeRunner{run: func(call fakeCall, stdout io.Writer) error {
+		if fakeScanner(call.Args) != 0 {
+			return errors.New("exit status 1")
+		}
+		return nil
+	}}
+	return Plugin{Config: config, Runner: runner, pollInterval: time.Millisecond}
+}
+
+func TestExecQualityGate(t *testing.T) {
+	tests := []struct {
+		name   string
+		states []string
+		gate   string
+		err    string
+	}{
+		{"passed", []string{"PENDING", "IN_PROGRESS", "SUCCESS"}, "OK", ""},
+		{"failed", []string{"IN_PROGRESS", "SUCCESS"}, "ERROR", "quality gate failed"},
+		{"task failed", []string{"PENDING", "FAILED"}, "", "ended with status FAILED"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chdir(t)
+			server := sonartest.NewServer()
+			defer server.Close()
+			server.Task("AXtask", "AXanalysis", test.states...)
+			server.QualityGate("AXanalysis", test.gate, sonartest.Condition{
+				Status:         test.gate,
+				MetricKey:      "new_coverage",
+				Comparator:     "LT",
+				ErrorThreshold: "80",
+				ActualValue:    "72.5",
+			})
+
+			p := sonarConfig(t, server)
+			p.Config.QualityGate = true
+			err := p.Exec()
+			if test.err == "" && err != nil {
+				t.Fatal(err)
+			}
+			if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+				t.Fatalf("got error %v, want %q", err, test.err)
+			}
+			if n := server.Calls("api/ce/task"); n != len(test.states) {
+				t.Errorf("got %d polls, want %d", n, len(test.states))
+			}
+		})
+	}
+}
+
+func TestExecReport(t *testing.T) {
+	dir := chdir(t)
+	server := sonartest.NewServer()
+	defer server.Close()
+	server.Task("AXtask", "AXanalysis", "PENDING", "SUCCESS")
+	server.QualityGate("AXanalysis", "OK")
+	server.Measures("octocat:hello-world", map[string]string{
+		"bugs":         "3",
+		"coverage":     "81.2",
+		"new_coverage": "90.0",
+	})
+
+	p := sonarConfig(t, server)
+	p.Config.Report = filepath.Join(dir, "out", "sonar")
+	p.Config.Card = true
+	p.Config.CardPath = filepath.Join(dir, "card.json")
+	if err := p.Exec(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(p.Config.Report + ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.QualityGate != "OK" || report.Measures["bugs"] != "3" || report.Measures["new_coverage"] != "90.0" {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	data, err = ioutil.ReadFile(p.Config.CardPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var card Card
+	if err := json.Unmarshal(data, &card); err != nil {
+		t.Fatal(err)
+	}
+	if want := server.URL + "/dashboard?id=octocat%3Ahello-world"; card.Data.DashboardURL != want {
+		t.Errorf("got dashboard %s, want %s", card.Data.DashboardURL, want)
+	}
+}
+
+func TestExecPreflight(t *testing.T) {
+	chdir(t)
+	server := sonartest.NewServer()
+	defer server.Close()
+	server.SystemStatus("STARTING")
+
+	p := sonarConfig(t, server)
+	p.Config.Preflight = true
+	err := p.Exec()
+	if err == nil || !strings.Contains(err.Error(), "is STARTING") {
+		t.Fatalf("got error %v, want the server starting", err)
+	}
+	if runner := p.Runner.(*fakeRunner); len(runner.calls) != 0 {
+		t.Errorf("scanner run %d times", len(runner.calls))
+	}
+}
+
+func TestExecCommunityEdition(t *testing.T) {
+	chdir(t)
+	server := sonartest.NewServer()
+	defer server.Close()
+	server.Handle("api/navigation/global", sonartest.JSON(map[string]interface{}{"edition": "community"}))
+
+	p := sonarConfig(t, server)
+	p.Config.BranchAnalysis = true
+	p.Config.Branch = "feature"
+	err := p.Exec()
+	if err == nil || !strings.Contains(err.Error(), "Community Edition") {
+		t.Fatalf("got error %v, want branch analysis refused", err)
+	}
+}
+
+func TestExecTimeoutWaitingQualityGate(t *testing.T) {
+	chdir(t)
+	server := sonartest.NewServer()
+	defer server.Close()
+	server.Task("AXtask", "AXanalysis", "PENDING")
+
+	p := sonarConfig(t, server)
+	p.Config.QualityGate = true
+	p.Config.QualityGateTimeout = "300"
+	p.Config.QualityGatePoll = "60"
+	p.pollInterval = 0
+	p.Config.RunTimeout = "1"
+	p.Config.CommitStatus = false
+
+	start := time.Now()
+	err := p.Exec()
+	if !errors.Is(err, errTimedOut) {
+		t.Fatalf("got error %v, want %v", err, errTimedOut)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("stopped after %s, want about 1s", elapsed)
+	}
+}
+
+func TestExecTokenProperties(t *testing.T) {
+	tests := []struct {
+		name    string
+		server  string
+		scanner string
+		want    string
+	}{
+		{"sonarqube 9", "9.9.0.65466", "", `{"sonar.login":"0123456789abcdef"}`},
+		{"sonarqube 10 with the image scanner", "10.4.1.88267", "", `{"sonar.login":"0123456789abcdef","sonar.token":"0123456789abcdef"}`},
+		{"sonarqube 10 with scanner 4", "10.4.1.88267", "4.8.1.3023", `{"sonar.login":"0123456789abcdef","sonar.token":"0123456789abcdef"}`},
+		{"sonarqube 10 with scanner 5", "10.4.1.88267", "5.0.1.3006", `{"sonar.token":"0123456789abcdef"}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := chdir(t)
+			server := sonartest.NewServer()
+			defer server.Close()
+			server.Handle("api/server/version", sonartest.Text(test.server))
+
+			p := sonarConfig(t, server)
+			if test.scanner != "" {
+				p.Config.ScannerVersion = test.scanner
+				p.Config.ScannerCache = dir
+				bin := p.scannerBin()
+				if err := os.MkdirAll(filepath.Dir(bin), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(bin, nil, 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := p.Exec(); err != nil {
+				t.Fatal(err)
+			}
+
+			env := p.Runner.(*fakeRunner).calls[0].Env
+			if want := "SONARQUBE_SCANNER_PARAMS=" + test.want; env[1] != want {
+				t.Errorf("got %s, want %s", env[1], want)
+			}
+		})
+	}
+}
+
+func TestExecPendingStatusFailure(t *testing.T) {
+	chdir(t)
+	server := sonartest.NewServer()
+	defer server.Close()
+	server.Task("AXtask", "AXanalysis", "SUCCESS")
+	server.QualityGate("AXanalysis", "OK")
+
+	var states []string
+	github := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var status struct {
+			State string `json:"state"`
+		}
+		json.NewDecoder(r.Body).Decode(&status)
+		states = append(states, status.State)
+		if status.State == "pending" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer github.Close()
+
+	p := sonarConfig(t, server)
+	p.Config.CommitStatus = true
+	p.Config.ScmProvider = "github"
+	p.Config.ScmServer = github.URL
+	p.Config.ScmToken = "ghp_token"
+	p.Config.Repo = "octocat/hello-world"
+	p.Config.Commit = "6dcb09b5b57875f334f61aebed695e2e4193db5e"
+	if err := p.Exec(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.Runner.(*fakeRunner).calls) != 1 {
+		t.Error("analysis not run")
+	}
+	if want := []string{"pending", "success"}; !reflect.DeepEqual(states, want) {
+		t.Errorf("got states %v, want %v", states, want)
+	}
+}
+
+func TestExecWorkingDirectory(t *testing.T) {
+	tests := []struct {
+		name       string
+		file       string
+		properties string
+		want       string
+	}{
+		{
+			name: "default",
+			want: ".scannerwork/report-task.txt",
+		},
+		{
+			name: "file",
+			file: "sonar.working.directory=build/sonar\n",
+			want: "build/sonar/report-task.txt",
+		},
+		{
+			name:       "properties over file",
+			file:       "sonar.working.directory=build/sonar\n",
+			properties: "sonar.working.directory=out/scanner",
+			want:       "out/scanner/report-task.txt",
+		},
+		{
+			name:       "relative to the base directory",
+			properties: "sonar.projectBaseDir=app\nsonar.working.directory=.work",
+			want:       "app/.work/report-task.txt",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chdir(t)
+			server := sonartest.NewServer()
+			defer server.Close()
+			server.Task("AXtask", "AXanalysis", "SUCCESS")
+			server.QualityGate("AXanalysis", "OK")
+
+			p := sonarConfig(t, server)
+			p.Config.QualityGate = true
+			p.Config.Properties = test.properties
+			if test.file != "" {
+				p.Config.UsingProperties = true
+				writeFiles(t, map[string]string{projectPropertiesFile: "sonar.projectKey=hello\n" + test.file})
+			}
+			if err := p.Exec(); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := os.Stat(filepath.FromSlash(test.want)); err != nil {
+				t.Errorf("report task not written to %s: %v", test.want, err)
+			}
+		})
+	}
+}
+
+func TestExecCardWithoutPath(t *testing.T) {
+	chdir(t)
+	server := sonartest.NewServer()
+	defer server.Close()
+	server.Task("AXtask", "AXanalysis", "SUCCESS")
+	server.QualityGate("AXanalysis", "OK")
+	server.Measures("octocat:hello-world", map[string]string{"bugs": "0"})
+
+	p := sonarConfig(t, server)
+	p.Config.Card = true
+	var err error
+	output := captureStdout(t, func() { err = p.Exec() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, "==> DRONE_CARD_PATH not set, skipping the card\n") {
+		t.Errorf("missing card warning in the output:\n%s", output)
+	}
+}
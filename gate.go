@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aosapps/drone-sonar-plugin/sonar"
+)
+
+// scannerWorkDir is the default sonar.working.directory of sonar-scanner.
+const scannerWorkDir = ".scannerwork"
+
+// ReportTask is the content of the report-task.txt file written by
+// sonar-scanner once the analysis report has been uploaded.
+type ReportTask struct {
+	ProjectKey   string
+	ServerURL    string
+	DashboardURL string
+	CeTaskID     string
+	CeTaskURL    string
+}
+
+// readReportTask parses the key=value pairs of report-task.txt.
+func readReportTask(path string) (*ReportTask, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	task := &ReportTask{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		kv := strings.SplitN(scanner.Text(), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "projectKey":
+			task.ProjectKey = kv[1]
+		case "serverUrl":
+			task.ServerURL = kv[1]
+		case "dashboardUrl":
+			task.DashboardURL = kv[1]
+		case "ceTaskId":
+			task.CeTaskID = kv[1]
+		case "ceTaskUrl":
+			task.CeTaskURL = kv[1]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if task.CeTaskID == "" {
+		return nil, fmt.Errorf("no ceTaskId found in %s", path)
+	}
+	return task, nil
+}
+
+// waitQualityGate waits for the background task of the analysis to finish
+// and returns the quality gate of the project.
+func (p Plugin) waitQualityGate(ctx context.Context, task *ReportTask) (*sonar.ProjectStatus, error) {
+	timeout, err := seconds(p.Config.QualityGateTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid quality gate timeout: %v", err)
+	}
+	interval, err := seconds(p.Config.QualityGatePoll)
+	if err != nil {
+		return nil, fmt.Errorf("invalid quality gate poll interval: %v", err)
+	}
+	if p.pollInterval > 0 {
+		interval = p.pollInterval
+	}
+
+	deadline := time.Now().Add(timeout)
+	var analysisID string
+	for {
+		ce, err := p.api.Task(ctx, task.CeTaskID)
+		if err != nil {
+			return nil, err
+		}
+
+		fmt.Printf("==> Background task %s: %s\n", task.CeTaskID, ce.Status)
+		if ce.Status == "SUCCESS" {
+			analysisID = ce.AnalysisID
+			break
+		}
+		if ce.Status != "PENDING" && ce.Status != "IN_PROGRESS" {
+			return nil, fmt.Errorf("background task %s ended with status %s %s", task.CeTaskID, ce.Status, ce.ErrorMessage)
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return nil, fmt.Errorf("background task %s not finished after %s", task.CeTaskID, timeout)
+		}
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return p.api.ProjectStatus(ctx, analysisID)
+}
+
+// seconds converts a number of seconds given as a string to a duration.
+func seconds(s string) (time.Duration, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(n) * time.Second, nil
+}
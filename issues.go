@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type (
+	// externalIssues is the SonarQube Generic Issue Import report.
+	externalIssues struct {
+		Issues []externalIssue `json:"issues"`
+	}
+
+	externalIssue struct {
+		EngineID        string        `json:"engineId"`
+		RuleID          string        `json:"ruleId"`
+		Severity        string        `json:"severity"`
+		Type            string        `json:"type"`
+		PrimaryLocation issueLocation `json:"primaryLocation"`
+	}
+
+	issueLocation struct {
+		Message   string     `json:"message"`
+		FilePath  string     `json:"filePath"`
+		TextRange *textRange `json:"textRange,omitempty"`
+	}
+
+	textRange struct {
+		StartLine int `json:"startLine"`
+	}
+
+	// lintIssue is an issue read from a linter report.
+	lintIssue struct {
+		Engine   string
+		Rule     string
+		Severity string
+		File     string
+		Line     int
+		Message  string
+	}
+
+	// golangciReport is the JSON output of golangci-lint.
+	golangciReport struct {
+		Issues []struct {
+			FromLinter string
+			Text       string
+			Severity   string
+			Pos        struct {
+				Filename string
+				Line     int
+			}
+		}
+	}
+
+	// checkstyleReport is a checkstyle XML report.
+	checkstyleReport struct {
+		Files []struct {
+			Name   string `xml:"name,attr"`
+			Errors []struct {
+				Line     int    `xml:"line,attr"`
+				Severity string `xml:"severity,attr"`
+				Message  string `xml:"message,attr"`
+				Source   string `xml:"source,attr"`
+			} `xml:"error"`
+		} `xml:"file"`
+	}
+)
+
+var (
+	issueSeverities = []string{"BLOCKER", "CRITICAL", "MAJOR", "MINOR", "INFO"}
+	issueTypes      = []string{"BUG", "VULNERABILITY", "CODE_SMELL"}
+
+	// defaultIssueSeverity maps the severities reported by linters.
+	defaultIssueSeverity = map[string]string{
+		"*":       "MAJOR",
+		"error":   "MAJOR",
+		"warning": "MINOR",
+		"info":    "INFO",
+	}
+	defaultIssueType = map[string]string{
+		"*": "CODE_SMELL",
+	}
+)
+
+// lintIssues converts the linter reports of Config.LintReports to
+// lintIssuesReport.
+func (p Plugin) lintIssues() error {
+	severities, err := parseMapping(p.Config.LintSeverity, defaultIssueSeverity, issueSeverities)
+	if err != nil {
+		return fmt.Errorf("invalid lint severity: %v", err)
+	}
+	types, err := parseMapping(p.Config.LintType, defaultIssueType, issueTypes)
+	if err != nil {
+		return fmt.Errorf("invalid lint type: %v", err)
+	}
+	reports, err := expandPaths(p.Config.LintReports)
+	if err != nil {
+		return err
+	}
+
+	report := externalIssues{Issues: []externalIssue{}}
+	for _, path := range reports {
+		issues, err := readLintReport(path)
+		if err != nil {
+			return err
+		}
+		for _, issue := range issues {
+			report.Issues = append(report.Issues, issue.external(severities, types))
+		}
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(lintIssuesReport), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(lintIssuesReport, data, 0644)
+}
+
+// readLintReport reads a golangci-lint JSON or a checkstyle XML report.
+func readLintReport(path string) ([]lintIssue, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+
+	var issues []lintIssue
+	if bytes.HasPrefix(data, []byte("<")) {
+		var report checkstyleReport
+		if err := xml.Unmarshal(data, &report); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		for _, f := range report.Files {
+			for _, e := range f.Errors {
+				issues = append(issues, lintIssue{
+					Engine:   "checkstyle",
+					Rule:     e.Source,
+					Severity: e.Severity,
+					File:     f.Name,
+					Line:     e.Line,
+					Message:  e.Message,
+				})
+			}
+		}
+		return issues, nil
+	}
+
+	var report golangciReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for _, i := range report.Issues {
+		issues = append(issues, lintIssue{
+			Engine:   "golangci-lint",
+			Rule:     i.FromLinter,
+			Severity: i.Severity,
+			File:     i.Pos.Filename,
+			Line:     i.Pos.Line,
+			Message:  i.Text,
+		})
+	}
+	return issues, nil
+}
+
+// external converts the issue, mapping its severity and type by rule, then
+// by reported severity, then with the "*" default.
+func (i lintIssue) external(severities, types map[string]string) externalIssue {
+	rule := i.Rule
+	if rule == "" {
+		rule = i.Engine
+	}
+	issue := externalIssue{
+		EngineID: i.Engine,
+		RuleID:   rule,
+		Severity: lookup(severities, rule, i.Severity),
+		Type:     lookup(types, rule, i.Severity),
+		PrimaryLocation: issueLocation{
+			Message:  i.Message,
+			FilePath: filepath.ToSlash(i.File),
+		},
+	}
+	if i.Line > 0 {
+		issue.PrimaryLocation.TextRange = &textRange{StartLine: i.Line}
+	}
+	return issue
+}
+
+func lookup(mapping map[string]string, keys ...string) string {
+	for _, key := range keys {
+		if v, ok := mapping[strings.ToLower(key)]; ok {
+			return v
+		}
+	}
+	return mapping["*"]
+}
+
+// parseMapping parses a comma-separated list of key=VALUE pairs over the
+// defaults, checking each value is one of allowed.
+func parseMapping(s string, defaults map[string]string, allowed []string) (map[string]string, error) {
+	mapping := map[string]string{}
+	for k, v := range defaults {
+		mapping[k] = v
+	}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("expected key=VALUE, got %s", pair)
+		}
+		value := strings.ToUpper(strings.TrimSpace(kv[1]))
+		if !contains(allowed, value) {
+			return nil, fmt.Errorf("%s is not one of %s", value, strings.Join(allowed, ", "))
+		}
+		mapping[strings.ToLower(strings.TrimSpace(kv[0]))] = value
+	}
+	return mapping, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
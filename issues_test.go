@@ -0,0 +1,187 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadLintReport(t *testing.T) {
+	tests := []struct {
+		name   string
+		report string
+		want   []lintIssue
+		err    string
+	}{
+		{
+			name: "golangci-lint",
+			report: `
+{
+  "Issues": [
+    {"FromLinter": "errcheck", "Text": "Error return value is not checked", "Severity": "", "Pos": {"Filename": "cmd/main.go", "Line": 12, "Column": 3}},
+    {"FromLinter": "gosec", "Text": "G104: Errors unhandled", "Severity": "warning", "Pos": {"Filename": "main.go", "Line": 0}}
+  ],
+  "Report": {"Linters": [{"Name": "errcheck", "Enabled": true}]}
+}`,
+			want: []lintIssue{
+				{Engine: "golangci-lint", Rule: "errcheck", File: "cmd/main.go", Line: 12, Message: "Error return value is not checked"},
+				{Engine: "golangci-lint", Rule: "gosec", Severity: "warning", File: "main.go", Message: "G104: Errors unhandled"},
+			},
+		},
+		{
+			name:   "golangci-lint without issues",
+			report: `{"Issues": null}`,
+		},
+		{
+			name: "checkstyle",
+			report: `<?xml version="1.0" encoding="UTF-8"?>
+<checkstyle version="5.0">
+  <file name="cmd/main.go">
+    <error line="12" column="3" severity="error" message="Error return value is not checked" source="errcheck"></error>
+    <error line="20" severity="warning" message="exported function should have comment"></error>
+  </file>
+  <file name="empty.go"></file>
+</checkstyle>`,
+			want: []lintIssue{
+				{Engine: "checkstyle", Rule: "errcheck", Severity: "error", File: "cmd/main.go", Line: 12, Message: "Error return value is not checked"},
+				{Engine: "checkstyle", Severity: "warning", File: "cmd/main.go", Line: 20, Message: "exported function should have comment"},
+			},
+		},
+		{
+			name:   "invalid json",
+			report: `{"Issues": [}`,
+			err:    "report: invalid character '}' looking for beginning of value",
+		},
+		{
+			name:   "invalid xml",
+			report: `<checkstyle><file name="main.go">`,
+			err:    "report: XML syntax error on line 1: unexpected EOF",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chdir(t)
+			writeFiles(t, map[string]string{"report": test.report})
+
+			got, err := readLintReport("report")
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("got error %v, want %s", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseMapping(t *testing.T) {
+	tests := []struct {
+		in   string
+		want map[string]string
+		err  string
+	}{
+		{
+			in:   "",
+			want: map[string]string{"*": "MAJOR", "error": "MAJOR", "warning": "MINOR", "info": "INFO"},
+		},
+		{
+			in:   " ErrCheck = critical, warning=INFO,,*=minor ",
+			want: map[string]string{"*": "MINOR", "error": "MAJOR", "warning": "INFO", "info": "INFO", "errcheck": "CRITICAL"},
+		},
+		{
+			in:  "errcheck",
+			err: "expected key=VALUE, got errcheck",
+		},
+		{
+			in:  "errcheck=FATAL",
+			err: "FATAL is not one of BLOCKER, CRITICAL, MAJOR, MINOR, INFO",
+		},
+	}
+
+	for _, test := range tests {
+		got, err := parseMapping(test.in, defaultIssueSeverity, issueSeverities)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%q: got error %v, want %s", test.in, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got %v, want %v", test.in, got, test.want)
+		}
+	}
+
+	if defaultIssueSeverity["*"] != "MAJOR" {
+		t.Errorf("parseMapping changed the defaults: %v", defaultIssueSeverity)
+	}
+}
+
+func TestLintIssueExternal(t *testing.T) {
+	severities, err := parseMapping("errcheck=CRITICAL,golangci-lint=BLOCKER", defaultIssueSeverity, issueSeverities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	types, err := parseMapping("gosec=VULNERABILITY,error=BUG", defaultIssueType, issueTypes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		issue lintIssue
+		want  externalIssue
+	}{
+		{
+			name:  "rule over reported severity",
+			issue: lintIssue{Engine: "golangci-lint", Rule: "ErrCheck", Severity: "warning", File: filepath.Join("cmd", "main.go"), Line: 12, Message: "unchecked"},
+			want: externalIssue{
+				EngineID: "golangci-lint", RuleID: "ErrCheck", Severity: "CRITICAL", Type: "CODE_SMELL",
+				PrimaryLocation: issueLocation{Message: "unchecked", FilePath: "cmd/main.go", TextRange: &textRange{StartLine: 12}},
+			},
+		},
+		{
+			name:  "reported severity over default",
+			issue: lintIssue{Engine: "checkstyle", Rule: "gosec", Severity: "Error", File: "main.go", Line: 3, Message: "G104"},
+			want: externalIssue{
+				EngineID: "checkstyle", RuleID: "gosec", Severity: "MAJOR", Type: "VULNERABILITY",
+				PrimaryLocation: issueLocation{Message: "G104", FilePath: "main.go", TextRange: &textRange{StartLine: 3}},
+			},
+		},
+		{
+			name:  "default",
+			issue: lintIssue{Engine: "checkstyle", Rule: "golint", Severity: "style", File: "main.go", Message: "comment"},
+			want: externalIssue{
+				EngineID: "checkstyle", RuleID: "golint", Severity: "MAJOR", Type: "CODE_SMELL",
+				PrimaryLocation: issueLocation{Message: "comment", FilePath: "main.go"},
+			},
+		},
+		{
+			name:  "engine as rule",
+			issue: lintIssue{Engine: "golangci-lint", Severity: "warning", File: "main.go", Line: 1, Message: "issue"},
+			want: externalIssue{
+				EngineID: "golangci-lint", RuleID: "golangci-lint", Severity: "BLOCKER", Type: "CODE_SMELL",
+				PrimaryLocation: issueLocation{Message: "issue", FilePath: "main.go", TextRange: &textRange{StartLine: 1}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.issue.external(severities, types)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"github.com/codegangsta/cli"
 	"os"
+	"strings"
 )
 
 var build = "1" // build number set at compile time
@@ -54,6 +56,57 @@ func main() {
 			Value:  "60",
 			EnvVar: "PLUGIN_TIMEOUT",
 		},
+		cli.BoolTFlag{
+			Name:   "preflight",
+			Usage:  "check the server and token before the analysis",
+			EnvVar: "PLUGIN_PREFLIGHT",
+		},
+		cli.BoolFlag{
+			Name:   "dryRun",
+			Usage:  "print the analysis command and properties without running it",
+			EnvVar: "PLUGIN_DRY_RUN",
+		},
+		cli.StringFlag{
+			Name:   "retryAttempts",
+			Usage:  "attempts of the analysis on network and server errors",
+			Value:  "3",
+			EnvVar: "PLUGIN_RETRYATTEMPTS",
+		},
+		cli.StringFlag{
+			Name:   "retryDelay",
+			Usage:  "seconds before the first retry, doubled on each retry",
+			Value:  "10",
+			EnvVar: "PLUGIN_RETRYDELAY",
+		},
+		cli.StringFlag{
+			Name:   "runTimeout",
+			Usage:  "seconds before the analysis is stopped, 0 for no limit",
+			Value:  "0",
+			EnvVar: "PLUGIN_RUNTIMEOUT",
+		},
+		cli.StringFlag{
+			Name:   "gracePeriod",
+			Usage:  "seconds given to the scanner to stop before it is killed",
+			Value:  "10",
+			EnvVar: "PLUGIN_GRACEPERIOD",
+		},
+		cli.BoolTFlag{
+			Name:   "qualityGate",
+			Usage:  "wait for the quality gate and fail on ERROR",
+			EnvVar: "PLUGIN_QUALITYGATE",
+		},
+		cli.StringFlag{
+			Name:   "qualityGateTimeout",
+			Usage:  "seconds to wait for the quality gate",
+			Value:  "300",
+			EnvVar: "PLUGIN_QUALITYGATETIMEOUT",
+		},
+		cli.StringFlag{
+			Name:   "qualityGatePoll",
+			Usage:  "seconds between quality gate checks",
+			Value:  "5",
+			EnvVar: "PLUGIN_QUALITYGATEPOLL",
+		},
 		cli.StringFlag{
 			Name:   "sources",
 			Usage:  "analysis sources",
@@ -87,6 +140,181 @@ func main() {
 			Usage:  "execute branchAnalysis",
 			EnvVar: "PLUGIN_BRANCHANALYSIS",
 		},
+		cli.StringFlag{
+			Name:   "pullRequestAnalysis",
+			Usage:  "execute pull request analysis (auto, true, false)",
+			Value:  "auto",
+			EnvVar: "PLUGIN_PULLREQUESTANALYSIS",
+		},
+		cli.StringFlag{
+			Name:   "event",
+			Usage:  "build event",
+			EnvVar: "DRONE_BUILD_EVENT",
+		},
+		cli.StringFlag{
+			Name:   "pullRequest",
+			Usage:  "pull request number",
+			EnvVar: "DRONE_PULL_REQUEST",
+		},
+		cli.StringFlag{
+			Name:   "sourceBranch",
+			Usage:  "pull request source branch",
+			EnvVar: "DRONE_SOURCE_BRANCH",
+		},
+		cli.StringFlag{
+			Name:   "targetBranch",
+			Usage:  "pull request target branch",
+			EnvVar: "DRONE_TARGET_BRANCH",
+		},
+		cli.StringFlag{
+			Name:   "report",
+			Usage:  "path of the analysis summary",
+			EnvVar: "PLUGIN_REPORT",
+		},
+		cli.BoolFlag{
+			Name:   "card",
+			Usage:  "write the analysis result as a Drone card",
+			EnvVar: "PLUGIN_CARD",
+		},
+		cli.StringFlag{
+			Name:   "cardPath",
+			Usage:  "Drone card path",
+			EnvVar: "DRONE_CARD_PATH",
+		},
+		cli.StringFlag{
+			Name:   "goCoverage",
+			Usage:  "Go coverprofiles to convert to generic coverage",
+			EnvVar: "PLUGIN_GOCOVERAGE",
+		},
+		cli.StringFlag{
+			Name:   "goTests",
+			Usage:  "go test -json outputs to convert to generic test executions",
+			EnvVar: "PLUGIN_GOTESTS",
+		},
+		cli.StringFlag{
+			Name:   "lintReports",
+			Usage:  "golangci-lint JSON or checkstyle reports to import as external issues",
+			EnvVar: "PLUGIN_LINTREPORTS",
+		},
+		cli.StringFlag{
+			Name:   "lintSeverity",
+			Usage:  "severity of imported issues by rule or reported severity",
+			EnvVar: "PLUGIN_LINTSEVERITY",
+		},
+		cli.StringFlag{
+			Name:   "lintType",
+			Usage:  "type of imported issues by rule or reported severity",
+			EnvVar: "PLUGIN_LINTTYPE",
+		},
+		cli.StringFlag{
+			Name:   "properties",
+			Usage:  "additional sonar properties",
+			EnvVar: "PLUGIN_PROPERTIES",
+		},
+		cli.BoolFlag{
+			Name:   "comment",
+			Usage:  "comment the analysis result on the pull request",
+			EnvVar: "PLUGIN_COMMENT",
+		},
+		cli.StringFlag{
+			Name:   "repo",
+			Usage:  "repository name",
+			EnvVar: "DRONE_REPO",
+		},
+		cli.StringFlag{
+			Name:   "scm",
+			Usage:  "scm provider (github, gitea, gitlab, bitbucket-server)",
+			EnvVar: "PLUGIN_SCM",
+		},
+		cli.StringFlag{
+			Name:   "scmServer",
+			Usage:  "scm API base URL",
+			EnvVar: "PLUGIN_SCM_SERVER",
+		},
+		cli.StringFlag{
+			Name:   "scmToken",
+			Usage:  "scm token",
+			EnvVar: "PLUGIN_SCM_TOKEN",
+		},
+		cli.BoolFlag{
+			Name:   "commitStatus",
+			Usage:  "set the quality gate as commit status",
+			EnvVar: "PLUGIN_COMMITSTATUS",
+		},
+		cli.StringFlag{
+			Name:   "commit",
+			Usage:  "commit sha",
+			EnvVar: "DRONE_COMMIT_SHA",
+		},
+		cli.BoolFlag{
+			Name:   "provision",
+			Usage:  "create the project before the first analysis",
+			EnvVar: "PLUGIN_PROVISION",
+		},
+		cli.StringFlag{
+			Name:   "provisionGate",
+			Usage:  "quality gate of the project",
+			EnvVar: "PLUGIN_PROVISIONGATE",
+		},
+		cli.StringFlag{
+			Name:   "provisionProfiles",
+			Usage:  "quality profiles of the project by language",
+			EnvVar: "PLUGIN_PROVISIONPROFILES",
+		},
+		cli.StringFlag{
+			Name:   "provisionTemplate",
+			Usage:  "permission template applied to a new project",
+			EnvVar: "PLUGIN_PROVISIONTEMPLATE",
+		},
+		cli.StringFlag{
+			Name:   "mode",
+			Usage:  "analysis mode (cli, maven, gradle, dotnet)",
+			Value:  "cli",
+			EnvVar: "PLUGIN_MODE",
+		},
+		cli.StringFlag{
+			Name:   "dotnetBuild",
+			Usage:  "build command of the dotnet mode",
+			Value:  "dotnet build",
+			EnvVar: "PLUGIN_DOTNETBUILD",
+		},
+		cli.StringFlag{
+			Name:   "dotnetTest",
+			Usage:  "test command of the dotnet mode",
+			EnvVar: "PLUGIN_DOTNETTEST",
+		},
+		cli.StringFlag{
+			Name:   "dotnetOpenCover",
+			Usage:  "OpenCover reports of the dotnet tests",
+			EnvVar: "PLUGIN_DOTNETOPENCOVER",
+		},
+		cli.StringFlag{
+			Name:   "dotnetCobertura",
+			Usage:  "Cobertura reports of the dotnet tests",
+			EnvVar: "PLUGIN_DOTNETCOBERTURA",
+		},
+		cli.StringFlag{
+			Name:   "scannerVersion",
+			Usage:  "sonar-scanner version",
+			EnvVar: "PLUGIN_SCANNER_VERSION",
+		},
+		cli.StringFlag{
+			Name:   "scannerMirror",
+			Usage:  "sonar-scanner download URL",
+			Value:  "https://binaries.sonarsource.com/Distribution/sonar-scanner-cli",
+			EnvVar: "PLUGIN_SCANNER_MIRROR",
+		},
+		cli.StringFlag{
+			Name:   "scannerChecksum",
+			Usage:  "SHA-256 of the sonar-scanner archive",
+			EnvVar: "PLUGIN_SCANNER_CHECKSUM",
+		},
+		cli.StringFlag{
+			Name:   "scannerCache",
+			Usage:  "sonar-scanner cache directory",
+			Value:  "/tmp/sonar-scanner",
+			EnvVar: "PLUGIN_SCANNER_CACHE",
+		},
 		cli.BoolFlag{
 			Name:   "usingProperties",
 			Usage:  "using sonar-project.properties",
@@ -105,22 +333,121 @@ func run(c *cli.Context) {
 			Host:  c.String("host"),
 			Token: c.String("token"),
 
-			Version:        c.String("ver"),
-			Branch:         c.String("branch"),
-			Timeout:        c.String("timeout"),
-			Sources:        c.String("sources"),
-			Inclusions:     c.String("inclusions"),
-			Exclusions:     c.String("exclusions"),
-			Level:          c.String("level"),
-			ShowProfiling:  c.String("showProfiling"),
-			BranchAnalysis: c.Bool("branchAnalysis"),
+			Version:         c.String("ver"),
+			Branch:          c.String("branch"),
+			Timeout:         c.String("timeout"),
+			Sources:         c.String("sources"),
+			Inclusions:      c.String("inclusions"),
+			Exclusions:      c.String("exclusions"),
+			Level:           c.String("level"),
+			ShowProfiling:   c.String("showProfiling"),
+			BranchAnalysis:  c.Bool("branchAnalysis"),
 			UsingProperties: c.Bool("usingProperties"),
 
+			QualityGate:        c.BoolT("qualityGate"),
+			QualityGateTimeout: c.String("qualityGateTimeout"),
+			QualityGatePoll:    c.String("qualityGatePoll"),
+
+			Event:               c.String("event"),
+			PullRequest:         c.String("pullRequest"),
+			SourceBranch:        c.String("sourceBranch"),
+			TargetBranch:        c.String("targetBranch"),
+			PullRequestAnalysis: c.String("pullRequestAnalysis"),
+
+			Report:   c.String("report"),
+			Card:     c.Bool("card"),
+			CardPath: c.String("cardPath"),
+
+			GoCoverage: c.String("goCoverage"),
+			GoTests:    c.String("goTests"),
+
+			LintReports:  c.String("lintReports"),
+			LintSeverity: c.String("lintSeverity"),
+			LintType:     c.String("lintType"),
+
+			Properties: c.String("properties"),
+
+			Repo:        c.String("repo"),
+			Comment:     c.Bool("comment"),
+			ScmProvider: c.String("scm"),
+			ScmServer:   c.String("scmServer"),
+			ScmToken:    c.String("scmToken"),
+
+			Commit:       c.String("commit"),
+			CommitStatus: c.Bool("commitStatus"),
+
+			Provision:         c.Bool("provision"),
+			ProvisionGate:     c.String("provisionGate"),
+			ProvisionProfiles: c.String("provisionProfiles"),
+			ProvisionTemplate: c.String("provisionTemplate"),
+
+			ScannerVersion:  c.String("scannerVersion"),
+			ScannerMirror:   c.String("scannerMirror"),
+			ScannerChecksum: c.String("scannerChecksum"),
+			ScannerCache:    c.String("scannerCache"),
+
+			Mode:            c.String("mode"),
+			DotnetBuild:     c.String("dotnetBuild"),
+			DotnetTest:      c.String("dotnetTest"),
+			DotnetOpenCover: c.String("dotnetOpenCover"),
+			DotnetCobertura: c.String("dotnetCobertura"),
+
+			Preflight: c.BoolT("preflight"),
+
+			RetryAttempts: c.String("retryAttempts"),
+			RetryDelay:    c.String("retryDelay"),
+
+			RunTimeout:  c.String("runTimeout"),
+			GracePeriod: c.String("gracePeriod"),
+
+			DryRun:  c.Bool("dryRun"),
+			Origins: origins(c),
 		},
 	}
 
 	if err := plugin.Exec(); err != nil {
 		fmt.Println(err)
+		switch {
+		case errors.Is(err, errCancelled):
+			os.Exit(130)
+		case errors.Is(err, errTimedOut):
+			os.Exit(124)
+		}
 		os.Exit(1)
 	}
 }
+
+// origins returns where the value of each setting comes from: the command
+// line, the first environment variable set, or the default value. Flags
+// without environment variable, such as help, are not settings.
+func origins(c *cli.Context) map[string]string {
+	origins := map[string]string{}
+	for _, f := range c.App.Flags {
+		var envVar string
+		switch f := f.(type) {
+		case cli.StringFlag:
+			envVar = f.EnvVar
+		case cli.BoolFlag:
+			envVar = f.EnvVar
+		case cli.BoolTFlag:
+			envVar = f.EnvVar
+		}
+		if envVar == "" {
+			continue
+		}
+
+		name := f.GetName()
+		origins[name] = "default"
+		if c.IsSet(name) {
+			origins[name] = "flag --" + name
+			continue
+		}
+		for _, env := range strings.Split(envVar, ",") {
+			if env = strings.TrimSpace(env); env != "" && os.Getenv(env) != "" {
+				origins[name] = "env " + env
+				break
+			}
+		}
+	}
+	return origins
+}
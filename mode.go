@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Analysis modes.
+const (
+	modeCLI    = "cli"
+	modeMaven  = "maven"
+	modeGradle = "gradle"
+)
+
+// buildToolProperties are left to the build tools, which know the source
+// directories of the project.
+var buildToolProperties = []string{
+	"sonar.sources",
+}
+
+// scan runs the analysis in the configured mode.
+func (p Plugin) scan(ctx context.Context, args, env []string) error {
+	if p.Config.Mode == modeDotnet {
+		return p.dotnet(ctx, args, env)
+	}
+	name, cmdArgs, err := p.scannerCommand(ctx, args)
+	if err != nil {
+		return err
+	}
+	return p.runRetry(ctx, name, cmdArgs, env)
+}
+
+// scannerCommand returns the command running the analysis in the configured
+// mode with the given scanner args.
+func (p Plugin) scannerCommand(ctx context.Context, args []string) (string, []string, error) {
+	switch p.Config.Mode {
+	case "", modeCLI:
+		if p.Config.ScannerVersion == "" {
+			return "sonar-scanner", args, nil
+		}
+		scanner, err := p.scannerPath(ctx)
+		return scanner, args, err
+	case modeMaven:
+		return wrapper("mvnw", "mvn"), append([]string{"-B", "sonar:sonar"}, args...), nil
+	case modeGradle:
+		return wrapper("gradlew", "gradle"), append([]string{"sonar"}, args...), nil
+	}
+	return "", nil, fmt.Errorf("invalid mode %q, expected cli, maven, gradle or dotnet", p.Config.Mode)
+}
+
+// reportTaskPath returns the report-task.txt file written in the mode. In
+// cli mode sonar-scanner writes it to sonar.working.directory, relative to
+// sonar.projectBaseDir.
+func (p Plugin) reportTaskPath(args []string, file map[string]string) string {
+	switch p.Config.Mode {
+	case modeMaven:
+		return filepath.Join("target", "sonar", "report-task.txt")
+	case modeGradle:
+		return filepath.Join("build", "sonar", "report-task.txt")
+	case modeDotnet:
+		return dotnetReportTaskFile
+	}
+	dir := argValue(args, file, "sonar.working.directory")
+	if dir == "" {
+		dir = scannerWorkDir
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(argValue(args, file, "sonar.projectBaseDir"), dir)
+	}
+	return filepath.Join(dir, "report-task.txt")
+}
+
+// buildTool reports whether the analysis is run by a build tool.
+func (p Plugin) buildTool() bool {
+	return p.Config.Mode == modeMaven || p.Config.Mode == modeGradle || p.Config.Mode == modeDotnet
+}
+
+// wrapper returns the wrapper script of the project when present, or the
+// build tool from PATH.
+func wrapper(script, tool string) string {
+	if _, err := os.Stat(script); err == nil {
+		return "./" + script
+	}
+	return tool
+}
+
+// withoutArgs removes the scanner args setting one of the keys.
+func withoutArgs(args []string, keys []string) []string {
+	var kept []string
+	for _, arg := range args {
+		if !contains(keys, argKey(arg)) {
+			kept = append(kept, arg)
+		}
+	}
+	return kept
+}
@@ -1,10 +1,16 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
-	"os/exec"
-	"strings"
+	"time"
+
+	"github.com/aosapps/drone-sonar-plugin/scm"
+	"github.com/aosapps/drone-sonar-plugin/sonar"
 )
 
 type (
@@ -14,58 +20,294 @@ type (
 		Host  string
 		Token string
 
-		Version        string
-		Branch         string
-		Sources        string
-		Timeout        string
-		Inclusions     string
-		Exclusions     string
-		Level          string
-		ShowProfiling  string
-		BranchAnalysis bool
+		Version         string
+		Branch          string
+		Sources         string
+		Timeout         string
+		Inclusions      string
+		Exclusions      string
+		Level           string
+		ShowProfiling   string
+		BranchAnalysis  bool
 		UsingProperties bool
+
+		QualityGate        bool
+		QualityGateTimeout string
+		QualityGatePoll    string
+
+		Event               string
+		PullRequest         string
+		SourceBranch        string
+		TargetBranch        string
+		PullRequestAnalysis string
+
+		Report   string
+		Card     bool
+		CardPath string
+
+		GoCoverage string
+		GoTests    string
+
+		LintReports  string
+		LintSeverity string
+		LintType     string
+
+		Properties string
+
+		Repo        string
+		Comment     bool
+		ScmProvider string
+		ScmServer   string
+		ScmToken    string
+
+		Commit       string
+		CommitStatus bool
+
+		Provision         bool
+		ProvisionGate     string
+		ProvisionProfiles string
+		ProvisionTemplate string
+
+		ScannerVersion  string
+		ScannerMirror   string
+		ScannerChecksum string
+		ScannerCache    string
+
+		Mode            string
+		DotnetBuild     string
+		DotnetTest      string
+		DotnetOpenCover string
+		DotnetCobertura string
+
+		Preflight bool
+
+		RetryAttempts string
+		RetryDelay    string
+
+		RunTimeout  string
+		GracePeriod string
+
+		DryRun bool
+		// Origins tells where each setting comes from, by flag name:
+		// "flag --name", "env NAME" or "default".
+		Origins map[string]string
 	}
 	Plugin struct {
 		Config Config
+		Runner Runner
+
+		// api is the Web API client of the server, set by Exec.
+		api *sonar.Client
+		// pollInterval replaces Config.QualityGatePoll when set, for tests.
+		pollInterval time.Duration
 	}
 )
 
+// credentialsEnv returns the environment passing the token to the scanner,
+// so that it does not appear in the process arguments.
+func (p Plugin) credentialsEnv(properties []string) ([]string, error) {
+	tokens := map[string]string{}
+	for _, property := range properties {
+		tokens[property] = p.Config.Token
+	}
+	params, err := json.Marshal(tokens)
+	if err != nil {
+		return nil, err
+	}
+	return []string{
+		"SONAR_TOKEN=" + p.Config.Token,
+		"SONARQUBE_SCANNER_PARAMS=" + string(params),
+	}, nil
+}
+
+// run executes the command with the token redacted from its output.
+func (p Plugin) run(ctx context.Context, name string, args, env []string) error {
+	return p.runOutput(ctx, name, args, env, ioutil.Discard)
+}
+
+// runOutput executes the command and also copies its output to w.
+func (p Plugin) runOutput(ctx context.Context, name string, args, env []string, w io.Writer) error {
+	runner, err := p.runner()
+	if err != nil {
+		return err
+	}
+	stdout := newRedactWriter(io.MultiWriter(os.Stdout, w), p.Config.Token)
+	stderr := newRedactWriter(io.MultiWriter(os.Stderr, w), p.Config.Token)
+
+	err = runner.Run(ctx, name, args, env, stdout, stderr)
+	stdout.Flush()
+	stderr.Flush()
+	return err
+}
+
 func (p Plugin) Exec() error {
-	args := []string{
-		"-Dsonar.host.url=" + p.Config.Host,
-		"-Dsonar.login=" + p.Config.Token,
-	}
-
-	if !p.Config.UsingProperties {
-		argsParameter := []string{
-			"-Dsonar.projectKey=" + strings.Replace(p.Config.Key, "/", ":", -1),
-			"-Dsonar.projectName=" + p.Config.Name,
-			"-Dsonar.projectVersion=" + p.Config.Version,
-			"-Dsonar.sources=" + p.Config.Sources,
-			"-Dsonar.ws.timeout=" + p.Config.Timeout,
-			"-Dsonar.inclusions=" + p.Config.Inclusions,
-			"-Dsonar.exclusions=" + p.Config.Exclusions,
-			"-Dsonar.log.level=" + p.Config.Level,
-			"-Dsonar.showProfiling=" + p.Config.ShowProfiling,
-			"-Dsonar.scm.provider=git",
+	if err := p.Config.Validate(); err != nil {
+		return err
+	}
+
+	ctx, stop, err := p.runContext()
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	return runError(ctx, p.exec(ctx))
+}
+
+// exec runs the analysis until ctx is done.
+func (p Plugin) exec(ctx context.Context) error {
+	timeout, err := seconds(p.Config.Timeout)
+	if err != nil {
+		return fmt.Errorf("invalid timeout: %v", err)
+	}
+	p.api = sonar.New(p.Config.Host, p.Config.Token, timeout)
+
+	server, err := p.detectServer(ctx)
+	if err != nil {
+		fmt.Printf("==> Unable to detect the SonarQube version: %v\n", err)
+		server = &Server{}
+	} else {
+		fmt.Printf("==> SonarQube %s, %s edition\n", server.Version, server.Edition)
+	}
+
+	pullRequest, err := p.pullRequestAnalysis()
+	if err != nil {
+		return err
+	}
+	if !server.supportsBranches() {
+		if pullRequest {
+			if mode := p.Config.PullRequestAnalysis; mode != "" && mode != "auto" {
+				return fmt.Errorf("pull request analysis is not supported by SonarQube %s Community Edition", server.Version)
+			}
+			fmt.Printf("==> Pull request analysis is not supported by Community Edition, skipping it\n")
+			p.Config.PullRequestAnalysis = "false"
+			pullRequest = false
+		}
+		if p.Config.BranchAnalysis {
+			return fmt.Errorf("branch analysis is not supported by SonarQube %s Community Edition", server.Version)
 		}
-		args = append(args, argsParameter...)
 	}
 
+	file := map[string]string{}
+	if p.Config.UsingProperties {
+		file, err = readProjectProperties(projectPropertiesFile)
+		if err != nil {
+			return err
+		}
+	}
+	props, err := parseProperties(p.Config.Properties)
+	if err != nil {
+		return err
+	}
 
-	if p.Config.BranchAnalysis {
-		args = append(args, "-Dsonar.branch.name=" + p.Config.Branch)
+	args := buildArgs(p.Config, file, props)
+	if p.Config.DryRun {
+		return p.dryRun(ctx, file, props, args, p.tokenProperties(server))
 	}
 
-	cmd := exec.Command("sonar-scanner", args...)
-	// fmt.Printf("==> Executing: %s\n", strings.Join(cmd.Args, " "))
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	fmt.Printf("==> Code Analysis Result:\n")
-	err := cmd.Run()
+	if p.Config.GoCoverage != "" {
+		if err := p.goCoverage(); err != nil {
+			return err
+		}
+	}
+	if p.Config.GoTests != "" {
+		if err := p.goTests(); err != nil {
+			return err
+		}
+	}
+	if p.Config.LintReports != "" {
+		if err := p.lintIssues(); err != nil {
+			return err
+		}
+	}
+
+	printEffectiveConfig(file, args, p.tokenProperties(server)...)
+
+	projectKey := argValue(args, file, "sonar.projectKey")
+	if p.Config.Preflight {
+		if err := p.preflight(ctx, projectKey); err != nil {
+			return err
+		}
+	}
+
+	if p.Config.Provision {
+		if err := p.provision(ctx, projectKey, argValue(args, file, "sonar.projectName")); err != nil {
+			return err
+		}
+	}
+
+	env, err := p.credentialsEnv(p.tokenProperties(server))
 	if err != nil {
 		return err
 	}
 
+	if p.Config.CommitStatus {
+		// a failing SCM API should not block the analysis
+		if err := p.setStatus(ctx, scm.Pending, "Analysis in progress", p.Config.Host); err != nil {
+			fmt.Printf("==> Unable to set commit status: %v\n", err)
+		}
+	}
+
+	fmt.Printf("==> Code Analysis Result:\n")
+	if err := p.scan(ctx, args, env); err != nil {
+		return p.failStatus(ctx, err)
+	}
+
+	comment := p.Config.Comment && pullRequest
+	if !p.Config.QualityGate && p.Config.Report == "" && !p.Config.Card && !comment && !p.Config.CommitStatus {
+		return nil
+	}
+
+	task, err := readReportTask(p.reportTaskPath(args, file))
+	if err != nil {
+		return p.failStatus(ctx, err)
+	}
+	gate, err := p.waitQualityGate(ctx, task)
+	if err != nil {
+		return p.failStatus(ctx, err)
+	}
+	fmt.Printf("==> Quality Gate: %s\n", gate.Status)
+
+	if p.Config.CommitStatus {
+		state, description := gateStatus(gate.Status)
+		if err := p.setStatus(ctx, state, description, p.dashboardURL(task.ProjectKey)); err != nil {
+			return err
+		}
+	}
+
+	if p.Config.Report != "" || p.Config.Card || comment {
+		measures, err := p.measures(ctx, task.ProjectKey, analysisMetrics)
+		if err != nil {
+			return err
+		}
+		report := &Report{
+			ProjectKey:   task.ProjectKey,
+			DashboardURL: task.DashboardURL,
+			QualityGate:  gate.Status,
+			Measures:     measures,
+		}
+		if p.Config.Report != "" {
+			if err := writeReport(p.Config.Report, report); err != nil {
+				return err
+			}
+		}
+		if p.Config.Card && p.Config.CardPath == "" {
+			fmt.Printf("==> DRONE_CARD_PATH not set, skipping the card\n")
+		} else if p.Config.Card {
+			if err := writeCard(p.Config.CardPath, p.card(report)); err != nil {
+				return err
+			}
+		}
+		if comment {
+			if err := p.comment(ctx, report); err != nil {
+				return err
+			}
+		}
+	}
+
+	if p.Config.QualityGate && gate.Status == "ERROR" {
+		return fmt.Errorf("quality gate failed, see %s", task.DashboardURL)
+	}
+
 	return nil
 }
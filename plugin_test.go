@@ -0,0 +1,431 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMain runs the test binary as the plugin when FAKE_SONAR_PLUGIN is
+// set, or as a fake sonar-scanner when FAKE_SONAR_SCANNER is set.
+func TestMain(m *testing.M) {
+	if os.Getenv("FAKE_SONAR_PLUGIN") != "" {
+		os.Unsetenv("FAKE_SONAR_PLUGIN")
+		main()
+		os.Exit(0)
+	}
+	if os.Getenv("FAKE_SONAR_SCANNER") != "" {
+		os.Exit(fakeScanner(os.Args[1:]))
+	}
+	os.Exit(m.Run())
+}
+
+// fakeScanner records its args to FAKE_SONAR_SCANNER_ARGS, sleeps for
+// FAKE_SONAR_SCANNER_SLEEP, writes the report-task.txt file of the analysis
+// and exits with FAKE_SONAR_SCANNER_EXIT.
+func fakeScanner(args []string) int {
+	fmt.Printf("INFO: Scanner configuration file: NONE\n")
+	fmt.Printf("INFO: token %s\n", os.Getenv("SONAR_TOKEN"))
+	if path := os.Getenv("FAKE_SONAR_SCANNER_ARGS"); path != "" {
+		if err := ioutil.WriteFile(path, []byte(strings.Join(args, "\n")), 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+	if d, err := time.ParseDuration(os.Getenv("FAKE_SONAR_SCANNER_SLEEP")); err == nil {
+		time.Sleep(d)
+	}
+	if code := os.Getenv("FAKE_SONAR_SCANNER_EXIT"); code != "" && code != "0" {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", os.Getenv("FAKE_SONAR_SCANNER_ERROR"))
+		return 1
+	}
+
+	props := map[string]string{}
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-D") {
+			props[argKey(arg)] = strings.SplitN(arg, "=", 2)[1]
+		}
+	}
+	task := os.Getenv("FAKE_SONAR_SCANNER_TASK")
+	if task == "" {
+		task = "AXtask"
+	}
+	host := props["sonar.host.url"]
+	report := fmt.Sprintf("projectKey=%s\nserverUrl=%s\nserverVersion=9.9.0\ndashboardUrl=%s/dashboard?id=%s\nceTaskId=%s\nceTaskUrl=%s/api/ce/task?id=%s\n",
+		props["sonar.projectKey"], host, host, props["sonar.projectKey"], task, host, task)
+	// like sonar-scanner, read sonar-project.properties when it exists
+	file, _ := readPropertiesFile(projectPropertiesFile)
+	path := Plugin{}.reportTaskPath(args, file)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if err := ioutil.WriteFile(path, []byte(report), 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	fmt.Printf("INFO: EXECUTION SUCCESS\n")
+	return 0
+}
+
+// installFakeScanner puts the fake sonar-scanner first in PATH.
+func installFakeScanner(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := tempDir(t)
+	if err := os.Symlink(exe, filepath.Join(dir, "sonar-scanner")); err != nil {
+		t.Fatal(err)
+	}
+	setenv(t, "PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	setenv(t, "FAKE_SONAR_SCANNER", "1")
+}
+
+// fakeRunner records the commands instead of running them.
+type fakeRunner struct {
+	calls []fakeCall
+	// run returns the result of a call, nil when unset.
+	run func(call fakeCall, stdout io.Writer) error
+}
+
+type fakeCall struct {
+	Name string
+	Args []string
+	Env  []string
+}
+
+func (r *fakeRunner) Run(ctx context.Context, name string, args, env []string, stdout, stderr io.Writer) error {
+	call := fakeCall{Name: name, Args: args, Env: env}
+	r.calls = append(r.calls, call)
+	if r.run != nil {
+		return r.run(call, stdout)
+	}
+	return nil
+}
+
+func (r *fakeRunner) commands() []string {
+	var commands []string
+	for _, c := range r.calls {
+		command := c.Name
+		for _, arg := range c.Args {
+			if !strings.HasPrefix(arg, "-D") && !strings.HasPrefix(arg, "/") {
+				command += " " + arg
+			}
+		}
+		commands = append(commands, command)
+	}
+	return commands
+}
+
+// testConfig returns a configuration without any Web API stage, against a
+// server answering 404 to every call.
+func testConfig(t *testing.T) Config {
+	server := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(server.Close)
+	return Config{
+		Key:           "octocat/hello-world",
+		Name:          "octocat/hello-world",
+		Host:          server.URL,
+		Token:         "0123456789abcdef",
+		Version:       "42",
+		Sources:       ".",
+		Timeout:       "60",
+		Level:         "INFO",
+		ShowProfiling: "false",
+		RetryAttempts: "1",
+		RetryDelay:    "0",
+		RunTimeout:    "0",
+		GracePeriod:   "1",
+
+		QualityGateTimeout: "300",
+		QualityGatePoll:    "5",
+	}
+}
+
+// tempDir returns a temporary directory removed at the end of the test.
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "drone-sonar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+// chdir runs the rest of the test in a new temporary directory.
+func chdir(t *testing.T) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := tempDir(t)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	return dir
+}
+
+func setenv(t *testing.T, key, value string) {
+	old, ok := os.LookupEnv(key)
+	os.Setenv(key, value)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func TestExecModes(t *testing.T) {
+	tests := []struct {
+		name   string
+		config func(*Config)
+		files  []string
+		want   []string
+	}{
+		{
+			name:   "cli",
+			config: func(c *Config) {},
+			want:   []string{"sonar-scanner"},
+		},
+		{
+			name:   "maven",
+			config: func(c *Config) { c.Mode = modeMaven },
+			want:   []string{"mvn -B sonar:sonar"},
+		},
+		{
+			name:   "maven wrapper",
+			config: func(c *Config) { c.Mode = modeMaven },
+			files:  []string{"mvnw"},
+			want:   []string{"./mvnw -B sonar:sonar"},
+		},
+		{
+			name:   "gradle wrapper",
+			config: func(c *Config) { c.Mode = modeGradle },
+			files:  []string{"gradlew"},
+			want:   []string{"./gradlew sonar"},
+		},
+		{
+			name: "dotnet",
+			config: func(c *Config) {
+				c.Mode = modeDotnet
+				c.DotnetBuild = "dotnet build"
+				c.DotnetTest = "dotnet test"
+			},
+			want: []string{
+				"dotnet sonarscanner begin",
+				"sh -c dotnet build",
+				"sh -c dotnet test",
+				"dotnet sonarscanner end",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chdir(t)
+			for _, f := range test.files {
+				if err := ioutil.WriteFile(f, nil, 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			runner := &fakeRunner{}
+			p := Plugin{Config: testConfig(t), Runner: runner}
+			test.config(&p.Config)
+
+			if err := p.Exec(); err != nil {
+				t.Fatal(err)
+			}
+			if got := runner.commands(); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got commands %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestExecCredentials(t *testing.T) {
+	chdir(t)
+	runner := &fakeRunner{}
+	p := Plugin{Config: testConfig(t), Runner: runner}
+
+	if err := p.Exec(); err != nil {
+		t.Fatal(err)
+	}
+	if len(runner.calls) != 1 {
+		t.Fatalf("got %d calls, want 1", len(runner.calls))
+	}
+	call := runner.calls[0]
+	for _, arg := range call.Args {
+		if strings.Contains(arg, p.Config.Token) {
+			t.Errorf("token passed as argument: %s", arg)
+		}
+	}
+	want := []string{
+		"SONAR_TOKEN=" + p.Config.Token,
+		`SONARQUBE_SCANNER_PARAMS={"sonar.login":"` + p.Config.Token + `"}`,
+	}
+	if !reflect.DeepEqual(call.Env, want) {
+		t.Errorf("got env %q, want %q", call.Env, want)
+	}
+}
+
+func TestExecDotnetCredentials(t *testing.T) {
+	chdir(t)
+	runner := &fakeRunner{}
+	p := Plugin{Config: testConfig(t), Runner: runner}
+	p.Config.Mode = modeDotnet
+	p.Config.DotnetBuild = "dotnet build"
+	p.Config.DotnetTest = "dotnet test"
+
+	if err := p.Exec(); err != nil {
+		t.Fatal(err)
+	}
+	for _, call := range runner.calls {
+		if call.Name == "sh" && call.Env != nil {
+			t.Errorf("credentials passed to %s: %q", call.Args[1], call.Env)
+		}
+		if call.Name == "dotnet" && len(call.Env) == 0 {
+			t.Errorf("credentials missing from dotnet %s", call.Args[1])
+		}
+	}
+}
+
+func TestExecDotnetBuildFailure(t *testing.T) {
+	chdir(t)
+	runner := &fakeRunner{run: func(call fakeCall, stdout io.Writer) error {
+		if call.Name == "sh" {
+			return errors.New("exit status 1")
+		}
+		return nil
+	}}
+	p := Plugin{Config: testConfig(t), Runner: runner}
+	p.Config.Mode = modeDotnet
+	p.Config.DotnetBuild = "dotnet build"
+
+	if err := p.Exec(); err == nil {
+		t.Fatal("expected the build failure")
+	}
+	want := []string{"dotnet sonarscanner begin", "sh -c dotnet build"}
+	if got := runner.commands(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got commands %q, want %q", got, want)
+	}
+}
+
+func TestExecRetry(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		calls  int
+	}{
+		{"network error", "ERROR: java.net.ConnectException: Connection refused", 3},
+		{"server error", "ERROR: Failed to upload report - HTTP code 502: Bad Gateway", 3},
+		{"configuration error", "ERROR: You must define the following mandatory properties", 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chdir(t)
+			runner := &fakeRunner{run: func(call fakeCall, stdout io.Writer) error {
+				fmt.Fprintln(stdout, test.output)
+				return errors.New("exit status 1")
+			}}
+			p := Plugin{Config: testConfig(t), Runner: runner}
+			p.Config.RetryAttempts = "3"
+
+			if err := p.Exec(); err == nil {
+				t.Fatal("expected the scanner failure")
+			}
+			if len(runner.calls) != test.calls {
+				t.Errorf("got %d calls, want %d", len(runner.calls), test.calls)
+			}
+		})
+	}
+}
+
+func TestExecFakeScanner(t *testing.T) {
+	installFakeScanner(t)
+	dir := chdir(t)
+	argsFile := filepath.Join(dir, "args.txt")
+	setenv(t, "FAKE_SONAR_SCANNER_ARGS", argsFile)
+
+	p := Plugin{Config: testConfig(t)}
+	p.Config.BranchAnalysis = true
+	p.Config.Branch = "feature"
+
+	output := captureStdout(t, func() {
+		if err := p.Exec(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	data, err := ioutil.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := buildArgs(p.Config, nil, nil)
+	if got := strings.Split(string(data), "\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got args\n%q\nwant\n%q", got, want)
+	}
+	if strings.Contains(output, p.Config.Token) {
+		t.Errorf("token not redacted from the scanner output:\n%s", output)
+	}
+	if !strings.Contains(output, "INFO: token *****") {
+		t.Errorf("redacted token missing from the scanner output:\n%s", output)
+	}
+	task, err := readReportTask(filepath.Join(scannerWorkDir, "report-task.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.ProjectKey != "octocat:hello-world" {
+		t.Errorf("got project key %s, want octocat:hello-world", task.ProjectKey)
+	}
+}
+
+func TestExecFakeScannerFailure(t *testing.T) {
+	installFakeScanner(t)
+	chdir(t)
+	setenv(t, "FAKE_SONAR_SCANNER_EXIT", "1")
+	setenv(t, "FAKE_SONAR_SCANNER_ERROR", "Not authorized")
+
+	p := Plugin{Config: testConfig(t)}
+	err := p.Exec()
+	if err == nil || !strings.Contains(err.Error(), string(failureConfig)) {
+		t.Errorf("got error %v, want a %s", err, failureConfig)
+	}
+}
+
+// captureStdout returns what f writes to os.Stdout.
+func captureStdout(t *testing.T, f func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	var buf bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		io.Copy(&buf, r)
+		close(done)
+	}()
+	f()
+	w.Close()
+	<-done
+	return buf.String()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/aosapps/drone-sonar-plugin/sonar"
+)
+
+// preflight checks the server is up, the token is valid and allowed to
+// analyse the project, before the scanner starts.
+func (p Plugin) preflight(ctx context.Context, projectKey string) error {
+	status, err := p.api.SystemStatus(ctx)
+	if err != nil {
+		return fmt.Errorf("SonarQube server %s unreachable: %v", p.Config.Host, err)
+	}
+	if status.Status != "UP" {
+		return fmt.Errorf("SonarQube server %s is %s", p.Config.Host, status.Status)
+	}
+
+	valid, err := p.api.ValidateToken(ctx)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return fmt.Errorf("SonarQube token invalid")
+	}
+
+	user, err := p.api.CurrentUser(ctx)
+	if err != nil {
+		return err
+	}
+	if !user.IsLoggedIn || contains(user.Permissions.Global, "scan") {
+		// project analysis tokens are not users and only work on their project
+		return nil
+	}
+
+	if _, err := p.api.Component(ctx, projectKey); err != nil {
+		var apiErr *sonar.Error
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+			return err
+		}
+		if !contains(user.Permissions.Global, "provisioning") && !p.Config.Provision {
+			return fmt.Errorf("project %s does not exist and %s has no Create Projects permission", projectKey, user.Login)
+		}
+		return nil
+	}
+
+	// the project permissions are only readable with the Administer
+	// permission, which ordinary CI tokens do not have
+	users, err := p.api.PermissionUsers(ctx, projectKey, "scan", user.Login)
+	var apiErr *sonar.Error
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusForbidden {
+		fmt.Printf("==> Skipping the Execute Analysis permission check: %s cannot read the permissions of %s without the Administer permission\n", user.Login, projectKey)
+		return nil
+	}
+	if err != nil {
+		fmt.Printf("==> Unable to check the permissions of %s on %s: %v\n", user.Login, projectKey, err)
+		return nil
+	}
+	for _, u := range users {
+		if u.Login == user.Login && contains(u.Permissions, "scan") {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s has no Execute Analysis permission on %s", user.Login, projectKey)
+}
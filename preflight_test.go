@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aosapps/drone-sonar-plugin/sonar"
+	"github.com/aosapps/drone-sonar-plugin/sonartest"
+)
+
+func TestPreflight(t *testing.T) {
+	user := sonartest.JSON(map[string]interface{}{
+		"login":       "octocat",
+		"isLoggedIn":  true,
+		"permissions": map[string][]string{"global": {}},
+	})
+	tests := []struct {
+		name        string
+		component   sonartest.Response
+		permissions sonartest.Response
+		err         string
+		output      string
+	}{
+		{
+			name:        "allowed",
+			component:   sonartest.JSON(map[string]string{"key": "octocat:hello-world"}),
+			permissions: sonartest.JSON(map[string]interface{}{"paging": map[string]int{"total": 1}, "users": []map[string]interface{}{{"login": "octocat", "permissions": []string{"scan"}}}}),
+		},
+		{
+			name:        "non-admin token",
+			component:   sonartest.JSON(map[string]string{"key": "octocat:hello-world"}),
+			permissions: sonartest.Error(http.StatusForbidden, "Insufficient privileges"),
+			output:      "==> Skipping the Execute Analysis permission check: octocat cannot read the permissions of octocat:hello-world without the Administer permission\n",
+		},
+		{
+			name:        "permissions unavailable",
+			component:   sonartest.JSON(map[string]string{"key": "octocat:hello-world"}),
+			permissions: sonartest.Error(http.StatusBadRequest, "Unknown permission"),
+			output:      "==> Unable to check the permissions of octocat on octocat:hello-world: ",
+		},
+		{
+			name:        "admin token without scan permission",
+			component:   sonartest.JSON(map[string]string{"key": "octocat:hello-world"}),
+			permissions: sonartest.JSON(map[string]interface{}{"paging": map[string]int{"total": 0}, "users": []interface{}{}}),
+			err:         "octocat has no Execute Analysis permission on octocat:hello-world",
+		},
+		{
+			name:      "missing project",
+			component: sonartest.Error(http.StatusNotFound, "Component key 'octocat:hello-world' not found"),
+			err:       "project octocat:hello-world does not exist and octocat has no Create Projects permission",
+		},
+		{
+			name:      "forbidden project",
+			component: sonartest.Error(http.StatusForbidden, "Insufficient privileges"),
+			err:       "GET api/navigation/component: 403 Forbidden: Insufficient privileges",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := sonartest.NewServer()
+			defer server.Close()
+			server.Handle("api/users/current", user)
+			server.Handle("api/navigation/component", test.component)
+			if test.permissions.Status != 0 {
+				server.Handle("api/permissions/users", test.permissions)
+			}
+
+			p := Plugin{Config: testConfig(t)}
+			p.api = sonar.New(server.URL, p.Config.Token, time.Minute)
+			var err error
+			output := captureStdout(t, func() {
+				err = p.preflight(context.Background(), "octocat:hello-world")
+			})
+			if !strings.Contains(output, test.output) {
+				t.Errorf("got output %q, want %q", output, test.output)
+			}
+			if test.err == "" && err != nil {
+				t.Fatal(err)
+			}
+			if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+				t.Errorf("got error %v, want %s", err, test.err)
+			}
+		})
+	}
+}
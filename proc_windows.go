@@ -0,0 +1,19 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup does nothing, process groups are not used on Windows.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// signalGroup sends the signal to the command.
+func signalGroup(cmd *exec.Cmd, sig os.Signal) error {
+	return cmd.Process.Signal(sig)
+}
+
+// killGroup kills the command.
+func killGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
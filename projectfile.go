@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const projectPropertiesFile = "sonar-project.properties"
+
+// fileOwnedProperties describe the project and are taken from
+// sonar-project.properties when it defines them. The other generated
+// parameters are build values and override the file.
+var fileOwnedProperties = []string{
+	"sonar.projectKey",
+	"sonar.projectName",
+	"sonar.sources",
+	"sonar.inclusions",
+	"sonar.exclusions",
+}
+
+// settingProperties are build values only passed with
+// sonar-project.properties when their setting is set explicitly, so that a
+// default value does not override the file.
+var settingProperties = []string{
+	"sonar.ws.timeout",
+	"sonar.log.level",
+	"sonar.showProfiling",
+}
+
+// moduleProperties are not passed to a multi-module project, where they
+// would be inherited by every module.
+var moduleProperties = []string{
+	"sonar.sources",
+	"sonar.inclusions",
+	"sonar.exclusions",
+}
+
+// readProjectProperties reads sonar-project.properties and the files of the
+// modules listed in sonar.modules, with module keys prefixed by the module.
+func readProjectProperties(path string) (map[string]string, error) {
+	props, err := readPropertiesFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return props, readModules(filepath.Dir(path), "", props)
+}
+
+func readModules(dir, prefix string, props map[string]string) error {
+	for _, module := range splitList(props[prefix+"sonar.modules"]) {
+		baseDir := props[prefix+module+".sonar.projectBaseDir"]
+		if baseDir == "" {
+			baseDir = module
+		}
+		baseDir = filepath.Join(dir, baseDir)
+
+		moduleProps, err := readPropertiesFile(filepath.Join(baseDir, projectPropertiesFile))
+		if os.IsNotExist(err) {
+			moduleProps = map[string]string{}
+		} else if err != nil {
+			return err
+		}
+		for k, v := range moduleProps {
+			key := prefix + module + "." + k
+			if _, ok := props[key]; !ok {
+				props[key] = v
+			}
+		}
+		if err := readModules(baseDir, prefix+module+".", props); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readPropertiesFile parses a Java properties file.
+func readPropertiesFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	props := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	var logical string
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimLeft(scanner.Text(), " \t\f")
+		if logical == "" && (line == "" || line[0] == '#' || line[0] == '!') {
+			continue
+		}
+
+		// an odd number of trailing backslashes continues the line
+		trailing := len(line) - len(strings.TrimRight(line, "\\"))
+		if trailing%2 == 1 {
+			logical += line[:len(line)-1]
+			continue
+		}
+		logical += line
+
+		key, value, err := splitProperty(logical)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		props[key] = value
+		logical = ""
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if logical != "" {
+		key, value, err := splitProperty(logical)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		props[key] = value
+	}
+	return props, nil
+}
+
+// splitProperty splits a logical line at the first unescaped '=', ':' or
+// whitespace and unescapes the key and the value.
+func splitProperty(line string) (string, string, error) {
+	end := len(line)
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.IndexByte("=: \t\f", line[i]) >= 0 {
+			end = i
+			break
+		}
+	}
+	rest := strings.TrimLeft(line[end:], " \t\f")
+	if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+		rest = strings.TrimLeft(rest[1:], " \t\f")
+	}
+
+	key, err := unescapeProperty(line[:end])
+	if err != nil {
+		return "", "", err
+	}
+	value, err := unescapeProperty(rest)
+	if err != nil {
+		return "", "", err
+	}
+	return key, value, nil
+}
+
+func unescapeProperty(s string) (string, error) {
+	if !strings.Contains(s, "\\") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+5 > len(s) {
+				return "", fmt.Errorf("invalid escape \\%s", s[i:])
+			}
+			r, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", fmt.Errorf("invalid escape \\%s", s[i:i+5])
+			}
+			b.WriteRune(rune(r))
+			i += 4
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// withoutFileProperties removes the generated parameters which are defined
+// by sonar-project.properties.
+func withoutFileProperties(params []string, file map[string]string) []string {
+	_, multiModule := file["sonar.modules"]
+	var kept []string
+	for _, arg := range params {
+		key := argKey(arg)
+		if _, ok := file[key]; ok && contains(fileOwnedProperties, key) {
+			continue
+		}
+		if multiModule && contains(moduleProperties, key) {
+			continue
+		}
+		kept = append(kept, arg)
+	}
+	return kept
+}
+
+// defaultProperties returns the settingProperties whose setting keeps its
+// default value.
+func (p Plugin) defaultProperties() []string {
+	var keys []string
+	for _, key := range settingProperties {
+		if p.origin(propertySettings[key]) == "default" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// printEffectiveConfig logs the properties seen by the scanner, the scanner
+// args overriding the file, with secrets redacted.
+func printEffectiveConfig(file map[string]string, args []string, secrets ...string) {
+	effective := map[string]string{}
+	for k, v := range file {
+		effective[k] = v
+	}
+	for _, arg := range args {
+		effective[argKey(arg)] = strings.SplitN(arg, "=", 2)[1]
+	}
+	for _, k := range secrets {
+		effective[k] = "*****"
+	}
+
+	keys := make([]string, 0, len(effective))
+	for k := range effective {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fmt.Printf("==> Effective Configuration:\n")
+	for _, k := range keys {
+		v := effective[k]
+		lower := strings.ToLower(k)
+		if strings.Contains(lower, "password") || strings.Contains(lower, "token") || strings.Contains(lower, "secret") {
+			v = "*****"
+		}
+		fmt.Printf("    %s=%s\n", k, v)
+	}
+}
+
+// argKey returns the property key of a -Dkey=value scanner arg.
+func argKey(arg string) string {
+	return strings.SplitN(strings.TrimPrefix(arg, "-D"), "=", 2)[0]
+}
+
+// splitList splits a comma-separated list, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSplitProperty(t *testing.T) {
+	tests := []struct {
+		line, key, value string
+		err              string
+	}{
+		{line: "sonar.sources=src", key: "sonar.sources", value: "src"},
+		{line: "sonar.sources = src ", key: "sonar.sources", value: "src "},
+		{line: "sonar.sources:src", key: "sonar.sources", value: "src"},
+		{line: "sonar.sources src", key: "sonar.sources", value: "src"},
+		{line: "sonar.sources\t: =src", key: "sonar.sources", value: "=src"},
+		{line: "sonar.sources", key: "sonar.sources", value: ""},
+		{line: `my\=key\ name=a=b`, key: "my=key name", value: "a=b"},
+		{line: `sonar.projectName=\u00c9t\u00E9 \\ 2020`, key: "sonar.projectName", value: `Été \ 2020`},
+		{line: `sonar.projectDescription=a\tb\nc\qd`, key: "sonar.projectDescription", value: "a\tb\ncqd"},
+		{line: `sonar.projectName=trailing\`, key: "sonar.projectName", value: `trailing\`},
+		{line: `sonar.projectName=\u00`, err: `invalid escape \u00`},
+		{line: `sonar.projectName=\uZZZZ`, err: `invalid escape \uZZZZ`},
+		{line: `sonar.\u12=x`, err: `invalid escape \u12`},
+	}
+
+	for _, test := range tests {
+		key, value, err := splitProperty(test.line)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s: got error %v, want %s", test.line, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.line, err)
+			continue
+		}
+		if key != test.key || value != test.value {
+			t.Errorf("%s: got %q=%q, want %q=%q", test.line, key, value, test.key, test.value)
+		}
+	}
+}
+
+func TestUnescapeProperty(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`plain`, "plain"},
+		{`C:\\dir\\file`, `C:\dir\file`},
+		{`\u0041\u00df\u4e2d`, "Aß中"},
+		{`a\rb\fc`, "a\rb\fc"},
+		{`\:\=\#\!`, ":=#!"},
+	}
+	for _, test := range tests {
+		got, err := unescapeProperty(test.in)
+		if err != nil {
+			t.Errorf("%s: %v", test.in, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: got %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestReadPropertiesFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]string
+		err     string
+	}{
+		{
+			name:    "comments and blank lines",
+			content: "# comment\n! other comment\n\n   \n  sonar.projectKey=hello\n",
+			want:    map[string]string{"sonar.projectKey": "hello"},
+		},
+		{
+			name:    "continuation lines",
+			content: "sonar.sources=src,\\\n    lib,\\\n\tcmd\nsonar.projectName=Hello\n",
+			want:    map[string]string{"sonar.sources": "src,lib,cmd", "sonar.projectName": "Hello"},
+		},
+		{
+			name:    "continuation of a comment-like line",
+			content: "sonar.projectDescription=issue \\\n#42\n",
+			want:    map[string]string{"sonar.projectDescription": "issue #42"},
+		},
+		{
+			name:    "escaped trailing backslash",
+			content: "sonar.working.directory=C:\\\\\nsonar.sources=src\n",
+			want:    map[string]string{"sonar.working.directory": `C:\`, "sonar.sources": "src"},
+		},
+		{
+			name:    "continuation at the end of the file",
+			content: "sonar.sources=src,\\\n  lib\\",
+			want:    map[string]string{"sonar.sources": "src,lib"},
+		},
+		{
+			name:    "later keys override",
+			content: "sonar.sources=src\nsonar.sources=lib\n",
+			want:    map[string]string{"sonar.sources": "lib"},
+		},
+		{
+			name:    "invalid escape",
+			content: "sonar.projectKey=hello\nsonar.projectName=\\u00g9\n",
+			err:     `sonar-project.properties:2: invalid escape \u00g9`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chdir(t)
+			writeFiles(t, map[string]string{projectPropertiesFile: test.content})
+
+			got, err := readPropertiesFile(projectPropertiesFile)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("got error %v, want %s", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestReadModules(t *testing.T) {
+	chdir(t)
+	writeFiles(t, map[string]string{
+		projectPropertiesFile: "sonar.modules=api, web,docs\n" +
+			"web.sonar.projectBaseDir=frontend\n" +
+			"api.sonar.projectName=API\n",
+		"api/" + projectPropertiesFile: "sonar.projectName=Backend\n" +
+			"sonar.sources=src\n" +
+			"sonar.modules=core\n" +
+			"core.sonar.projectBaseDir=lib/core\n",
+		"api/lib/core/" + projectPropertiesFile: "sonar.sources=.\n",
+		"frontend/" + projectPropertiesFile:     "sonar.sources=app\n",
+		"web/" + projectPropertiesFile:          "sonar.sources=ignored\n",
+	})
+
+	got, err := readProjectProperties(projectPropertiesFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"sonar.modules":                 "api, web,docs",
+		"web.sonar.projectBaseDir":      "frontend",
+		"api.sonar.projectName":         "API",
+		"api.sonar.sources":             "src",
+		"api.sonar.modules":             "core",
+		"api.core.sonar.projectBaseDir": "lib/core",
+		"api.core.sonar.sources":        ".",
+		"web.sonar.sources":             "app",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestReadModulesInvalid(t *testing.T) {
+	chdir(t)
+	writeFiles(t, map[string]string{
+		projectPropertiesFile:          "sonar.modules=api\n",
+		"api/" + projectPropertiesFile: "sonar.projectName=\\u12\n",
+	})
+
+	_, err := readProjectProperties(projectPropertiesFile)
+	want := filepath.Join("api", projectPropertiesFile) + `:1: invalid escape \u12`
+	if err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// reservedProperties can only be set through the host and token settings.
+var reservedProperties = []string{
+	"sonar.host.url",
+	"sonar.login",
+	"sonar.password",
+	"sonar.token",
+}
+
+// parseProperties parses the properties setting, given either as a JSON map
+// (as Drone passes a YAML map) or as key=value or key: value lines.
+func parseProperties(s string) (map[string]string, error) {
+	props := map[string]string{}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return props, nil
+	}
+
+	if strings.HasPrefix(s, "{") {
+		// numbers are kept as written, 10000000 rather than 1e+07
+		var m map[string]interface{}
+		dec := json.NewDecoder(strings.NewReader(s))
+		dec.UseNumber()
+		if err := dec.Decode(&m); err != nil {
+			return nil, fmt.Errorf("invalid properties: %v", err)
+		}
+		if dec.More() {
+			return nil, fmt.Errorf("invalid properties: unexpected content after the JSON map")
+		}
+		for k, v := range m {
+			switch v := v.(type) {
+			case string:
+				props[k] = v
+			case json.Number, bool:
+				props[k] = fmt.Sprint(v)
+			default:
+				return nil, fmt.Errorf("invalid properties: value of %s is not a string, number or boolean", k)
+			}
+		}
+	} else {
+		for n, line := range strings.Split(s, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			i := strings.IndexAny(line, "=:")
+			if i <= 0 {
+				return nil, fmt.Errorf("invalid properties: line %d: expected key=value", n+1)
+			}
+			props[strings.TrimSpace(line[:i])] = strings.Trim(strings.TrimSpace(line[i+1:]), `"'`)
+		}
+	}
+
+	for k := range props {
+		if contains(reservedProperties, k) {
+			return nil, fmt.Errorf("invalid properties: %s cannot be overridden, use the host and token settings", k)
+		}
+	}
+	return props, nil
+}
+
+// mergeProperties sets the properties in the scanner args, replacing the
+// generated values of the same keys.
+func mergeProperties(args []string, props map[string]string) []string {
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		arg := "-D" + k + "=" + props[k]
+		replaced := false
+		for i, a := range args {
+			if strings.HasPrefix(a, "-D"+k+"=") {
+				args[i] = arg
+				replaced = true
+			}
+		}
+		if !replaced {
+			args = append(args, arg)
+		}
+	}
+	return args
+}
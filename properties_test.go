@@ -0,0 +1,122 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseProperties(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want map[string]string
+		err  string
+	}{
+		{
+			name: "empty",
+			in:   "  \n",
+			want: map[string]string{},
+		},
+		{
+			name: "json",
+			in:   `{"sonar.sourceEncoding": "UTF-8", "sonar.cpd.go.minimumTokens": 10000000, "sonar.ratio": 0.5, "sonar.scm.disabled": true}`,
+			want: map[string]string{
+				"sonar.sourceEncoding":       "UTF-8",
+				"sonar.cpd.go.minimumTokens": "10000000",
+				"sonar.ratio":                "0.5",
+				"sonar.scm.disabled":         "true",
+			},
+		},
+		{
+			name: "json with a nested value",
+			in:   `{"sonar.modules": ["api", "web"]}`,
+			err:  "invalid properties: value of sonar.modules is not a string, number or boolean",
+		},
+		{
+			name: "invalid json",
+			in:   `{"sonar.sourceEncoding": }`,
+			err:  "invalid properties: invalid character '}' looking for beginning of value",
+		},
+		{
+			name: "content after json",
+			in:   `{"sonar.sourceEncoding": "UTF-8"} {}`,
+			err:  "invalid properties: unexpected content after the JSON map",
+		},
+		{
+			name: "lines",
+			in:   "# comment\nsonar.sourceEncoding=UTF-8\n\n  sonar.exclusions: **/vendor/** \nsonar.projectDescription = \"Hello: world\"\nsonar.links.ci='https://ci.example.com'",
+			want: map[string]string{
+				"sonar.sourceEncoding":     "UTF-8",
+				"sonar.exclusions":         "**/vendor/**",
+				"sonar.projectDescription": "Hello: world",
+				"sonar.links.ci":           "https://ci.example.com",
+			},
+		},
+		{
+			name: "line without separator",
+			in:   "sonar.sourceEncoding=UTF-8\nsonar.verbose",
+			err:  "invalid properties: line 2: expected key=value",
+		},
+		{
+			name: "line without key",
+			in:   "=UTF-8",
+			err:  "invalid properties: line 1: expected key=value",
+		},
+		{
+			name: "reserved key in lines",
+			in:   "sonar.login=secret",
+			err:  "invalid properties: sonar.login cannot be overridden, use the host and token settings",
+		},
+		{
+			name: "reserved key in json",
+			in:   `{"sonar.host.url": "http://other"}`,
+			err:  "invalid properties: sonar.host.url cannot be overridden, use the host and token settings",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseProperties(test.in)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("got error %v, want %s", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMergeProperties(t *testing.T) {
+	args := []string{
+		"-Dsonar.projectKey=octocat:hello-world",
+		"-Dsonar.projectVersion=42",
+		"-Dsonar.sources=.",
+		"-Dsonar.sourcesExtra=x",
+	}
+	props := map[string]string{
+		"sonar.sources":        "src",
+		"sonar.projectVersion": "1.0",
+		"sonar.z":              "last",
+		"sonar.a":              "first",
+	}
+
+	got := mergeProperties(args, props)
+	want := []string{
+		"-Dsonar.projectKey=octocat:hello-world",
+		"-Dsonar.projectVersion=1.0",
+		"-Dsonar.sources=src",
+		"-Dsonar.sourcesExtra=x",
+		"-Dsonar.a=first",
+		"-Dsonar.z=last",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// provision creates the project on the first analysis, and assigns its
+// quality gate and quality profiles. The permission template is only applied
+// to new projects, so that permissions changed later are kept.
+func (p Plugin) provision(ctx context.Context, key, name string) error {
+	profiles, err := parseProfiles(p.Config.ProvisionProfiles)
+	if err != nil {
+		return err
+	}
+	projects, err := p.api.SearchProjects(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	if len(projects) == 0 {
+		if name == "" {
+			name = key
+		}
+		fmt.Printf("==> Creating project %s\n", key)
+		if err := p.api.CreateProject(ctx, key, name); err != nil {
+			return err
+		}
+		if p.Config.ProvisionTemplate != "" {
+			if err := p.api.ApplyTemplate(ctx, key, p.Config.ProvisionTemplate); err != nil {
+				return err
+			}
+		}
+	}
+
+	if p.Config.ProvisionGate != "" {
+		if err := p.api.SelectQualityGate(ctx, key, p.Config.ProvisionGate); err != nil {
+			return err
+		}
+	}
+
+	for _, profile := range profiles {
+		if err := p.api.AddQualityProfile(ctx, key, profile[0], profile[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseProfiles parses comma-separated language=profile pairs.
+func parseProfiles(s string) ([][2]string, error) {
+	var profiles [][2]string
+	for _, pair := range splitList(s) {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" || strings.TrimSpace(kv[1]) == "" {
+			return nil, fmt.Errorf("invalid quality profile %q, expected language=profile", pair)
+		}
+		profiles = append(profiles, [2]string{strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])})
+	}
+	return profiles, nil
+}
+
+// argValue returns the value of a property in the scanner args, or in
+// sonar-project.properties when the args do not set it.
+func argValue(args []string, file map[string]string, key string) string {
+	for _, arg := range args {
+		if argKey(arg) == key {
+			return strings.SplitN(arg, "=", 2)[1]
+		}
+	}
+	return file[key]
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aosapps/drone-sonar-plugin/sonar"
+	"github.com/aosapps/drone-sonar-plugin/sonartest"
+)
+
+func TestProvision(t *testing.T) {
+	existing := sonartest.JSON(map[string]interface{}{
+		"paging":     map[string]int{"pageIndex": 1, "pageSize": 100, "total": 1},
+		"components": []map[string]string{{"key": "octocat:hello-world", "name": "Hello World"}},
+	})
+	missing := sonartest.JSON(map[string]interface{}{
+		"paging":     map[string]int{"pageIndex": 1, "pageSize": 100, "total": 0},
+		"components": []interface{}{},
+	})
+
+	tests := []struct {
+		name     string
+		search   sonartest.Response
+		projName string
+		profiles string
+		want     []string
+		err      string
+	}{
+		{
+			name:     "existing project",
+			search:   existing,
+			profiles: "go=Sonar way, js = Strict",
+			want: []string{
+				"GET /api/projects/search projects=octocat:hello-world",
+				"POST /api/qualitygates/select gateName=Strict gate projectKey=octocat:hello-world",
+				"POST /api/qualityprofiles/add_project language=go project=octocat:hello-world qualityProfile=Sonar way",
+				"POST /api/qualityprofiles/add_project language=js project=octocat:hello-world qualityProfile=Strict",
+			},
+		},
+		{
+			name:     "new project",
+			search:   missing,
+			projName: "Hello World",
+			profiles: "go=Sonar way",
+			want: []string{
+				"GET /api/projects/search projects=octocat:hello-world",
+				"POST /api/projects/create name=Hello World project=octocat:hello-world",
+				"POST /api/permissions/apply_template projectKey=octocat:hello-world templateName=CI projects",
+				"POST /api/qualitygates/select gateName=Strict gate projectKey=octocat:hello-world",
+				"POST /api/qualityprofiles/add_project language=go project=octocat:hello-world qualityProfile=Sonar way",
+			},
+		},
+		{
+			name:   "new project without name",
+			search: missing,
+			want: []string{
+				"GET /api/projects/search projects=octocat:hello-world",
+				"POST /api/projects/create name=octocat:hello-world project=octocat:hello-world",
+				"POST /api/permissions/apply_template projectKey=octocat:hello-world templateName=CI projects",
+				"POST /api/qualitygates/select gateName=Strict gate projectKey=octocat:hello-world",
+			},
+		},
+		{
+			name:     "invalid profiles",
+			search:   missing,
+			profiles: "go=Sonar way,js",
+			err:      `invalid quality profile "js", expected language=profile`,
+		},
+		{
+			name:     "profile without language",
+			search:   missing,
+			profiles: "=Sonar way",
+			err:      `invalid quality profile "=Sonar way", expected language=profile`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := sonartest.NewServer()
+			defer server.Close()
+			server.Handle("api/projects/search", test.search)
+			for _, path := range []string{"api/projects/create", "api/permissions/apply_template", "api/qualitygates/select", "api/qualityprofiles/add_project"} {
+				server.Handle(path, sonartest.Response{Status: 204})
+			}
+
+			p := Plugin{Config: testConfig(t)}
+			p.Config.ProvisionTemplate = "CI projects"
+			p.Config.ProvisionGate = "Strict gate"
+			p.Config.ProvisionProfiles = test.profiles
+			p.api = sonar.New(server.URL, p.Config.Token, time.Minute)
+
+			err := p.provision(context.Background(), "octocat:hello-world", test.projName)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Errorf("got error %v, want %s", err, test.err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if got := requestLines(server.Requests()); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got requests\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+			}
+		})
+	}
+}
+
+// requestLines formats the requests as "METHOD path key=value...", with
+// sorted params.
+func requestLines(requests []sonartest.Request) []string {
+	var lines []string
+	for _, r := range requests {
+		var params []string
+		for k, v := range r.Params {
+			if k != "p" && k != "ps" {
+				params = append(params, k+"="+strings.Join(v, ","))
+			}
+		}
+		sort.Strings(params)
+		lines = append(lines, strings.Join(append([]string{r.Method, r.Path}, params...), " "))
+	}
+	return lines
+}
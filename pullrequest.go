@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// pullRequestAnalysis reports whether the analysis should be run as a pull
+// request analysis. In auto mode it is enabled on pull_request events.
+func (p Plugin) pullRequestAnalysis() (bool, error) {
+	mode := p.Config.PullRequestAnalysis
+	if mode == "" || mode == "auto" {
+		return p.Config.Event == "pull_request" && p.Config.PullRequest != "", nil
+	}
+
+	enabled, err := strconv.ParseBool(mode)
+	if err != nil {
+		return false, fmt.Errorf("invalid pull request analysis mode %q, expected auto, true or false", mode)
+	}
+	if enabled && (p.Config.PullRequest == "" || p.Config.SourceBranch == "" || p.Config.TargetBranch == "") {
+		return false, fmt.Errorf("pull request analysis requires the pull request number, source and target branch")
+	}
+	return enabled, nil
+}
+
+// pullRequestArgs returns the scanner parameters of a pull request analysis.
+func (p Plugin) pullRequestArgs() []string {
+	return []string{
+		"-Dsonar.pullrequest.key=" + p.Config.PullRequest,
+		"-Dsonar.pullrequest.branch=" + p.Config.SourceBranch,
+		"-Dsonar.pullrequest.base=" + p.Config.TargetBranch,
+	}
+}
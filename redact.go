@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"io"
+)
+
+// redactWriter masks every occurrence of a secret in the output written to
+// the underlying writer. Output is buffered by line so that a secret split
+// across two writes is still masked.
+type redactWriter struct {
+	w      io.Writer
+	secret []byte
+	buf    bytes.Buffer
+}
+
+func newRedactWriter(w io.Writer, secret string) *redactWriter {
+	return &redactWriter{w: w, secret: []byte(secret)}
+}
+
+func (r *redactWriter) Write(p []byte) (int, error) {
+	r.buf.Write(p)
+	if i := bytes.LastIndexByte(r.buf.Bytes(), '\n'); i >= 0 {
+		line := r.buf.Next(i + 1)
+		if _, err := r.w.Write(r.redact(line)); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush writes any remaining buffered output.
+func (r *redactWriter) Flush() error {
+	if r.buf.Len() == 0 {
+		return nil
+	}
+	_, err := r.w.Write(r.redact(r.buf.Next(r.buf.Len())))
+	return err
+}
+
+func (r *redactWriter) redact(b []byte) []byte {
+	if len(r.secret) == 0 {
+		return b
+	}
+	return bytes.Replace(b, r.secret, []byte("*****"), -1)
+}
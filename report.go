@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type (
+	// Metric is a SonarQube metric shown in the analysis summary.
+	Metric struct {
+		Key   string
+		Label string
+	}
+
+	// Report is the analysis summary written for later pipeline steps.
+	Report struct {
+		ProjectKey   string            `json:"projectKey"`
+		DashboardURL string            `json:"dashboardUrl"`
+		QualityGate  string            `json:"qualityGate"`
+		Measures     map[string]string `json:"measures"`
+	}
+)
+
+// reportMetrics are the key measures of the analysis summary.
+var reportMetrics = []Metric{
+	{"bugs", "Bugs"},
+	{"vulnerabilities", "Vulnerabilities"},
+	{"code_smells", "Code Smells"},
+	{"coverage", "Coverage"},
+	{"duplicated_lines_density", "Duplication"},
+}
+
+// analysisMetrics are all the measures fetched once the analysis is processed.
+var analysisMetrics = append(append([]Metric{}, reportMetrics...), newCodeMetrics...)
+
+// measures returns the values of the given metrics for the analysed
+// branch or pull request of the project.
+func (p Plugin) measures(ctx context.Context, projectKey string, metrics []Metric) (map[string]string, error) {
+	keys := make([]string, len(metrics))
+	for i, m := range metrics {
+		keys[i] = m.Key
+	}
+	measures, err := p.api.Measures(ctx, p.componentQuery(projectKey), keys)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+	for _, m := range measures {
+		values[m.Metric] = m.Value
+	}
+	return values, nil
+}
+
+// componentQuery selects the analysed branch or pull request of a project
+// in Web API calls.
+func (p Plugin) componentQuery(projectKey string) url.Values {
+	query := url.Values{"component": {projectKey}}
+	if pullRequest, _ := p.pullRequestAnalysis(); pullRequest {
+		query.Set("pullRequest", p.Config.PullRequest)
+	} else if p.Config.BranchAnalysis {
+		query.Set("branch", p.Config.Branch)
+	}
+	return query
+}
+
+// writeReport writes the summary to path with the .md and .json extensions.
+func writeReport(path string, report *Report) error {
+	path = strings.TrimSuffix(path, filepath.Ext(path))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path+".json", data, 0644); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path+".md", report.Markdown(), 0644)
+}
+
+// Markdown renders the summary as a Markdown table.
+func (r *Report) Markdown() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "## SonarQube analysis of `%s`\n\n", r.ProjectKey)
+	fmt.Fprintf(&buf, "**Quality Gate:** %s\n\n", r.QualityGate)
+	fmt.Fprintf(&buf, "| Metric | Value |\n|---|---|\n")
+	for _, m := range reportMetrics {
+		fmt.Fprintf(&buf, "| %s | %s |\n", m.Label, formatMeasure(m.Key, r.Measures[m.Key]))
+	}
+	if r.DashboardURL != "" {
+		fmt.Fprintf(&buf, "\n[Open in SonarQube](%s)\n", r.DashboardURL)
+	}
+	return buf.Bytes()
+}
+
+// formatMeasure formats a measure value for display.
+func formatMeasure(metric, value string) string {
+	if value == "" {
+		return "-"
+	}
+	if strings.HasSuffix(metric, "coverage") || strings.HasSuffix(metric, "density") {
+		return value + "%"
+	}
+	return value
+}
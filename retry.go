@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// failureClass is the cause of a scanner failure, read from its output.
+type failureClass string
+
+const (
+	failureNetwork failureClass = "network error"
+	failureServer  failureClass = "server error"
+	failureConfig  failureClass = "configuration error"
+)
+
+var (
+	networkFailure = regexp.MustCompile(`(?i)(UnknownHostException|ConnectException|SocketTimeoutException|SocketException|Connection (refused|reset)|connect timed out|Read timed out|Failed to connect|unexpected end of stream)`)
+	serverFailure  = regexp.MustCompile(`(?i)(HTTP (code )?5\d\d|status code:? 5\d\d|returned 5\d\d|\b50[0234]\b.*(Error|Gateway|Unavailable|Timeout)|Bad Gateway|Service Unavailable|Gateway Time-?out)`)
+)
+
+// classifyFailure returns the class of a failure from the scanner output.
+func classifyFailure(output []byte) failureClass {
+	switch {
+	case serverFailure.Match(output):
+		return failureServer
+	case networkFailure.Match(output):
+		return failureNetwork
+	}
+	return failureConfig
+}
+
+// transient reports whether the failure may succeed when retried.
+func (c failureClass) transient() bool {
+	return c == failureNetwork || c == failureServer
+}
+
+// runRetry runs the command, retrying network and server failures with an
+// exponential backoff up to Config.RetryAttempts attempts.
+func (p Plugin) runRetry(ctx context.Context, name string, args, env []string) error {
+	attempts, err := strconv.Atoi(p.Config.RetryAttempts)
+	if err != nil || attempts < 1 {
+		return fmt.Errorf("invalid retry attempts %q", p.Config.RetryAttempts)
+	}
+	delay, err := seconds(p.Config.RetryDelay)
+	if err != nil {
+		return fmt.Errorf("invalid retry delay: %v", err)
+	}
+
+	for attempt := 1; ; attempt++ {
+		var output tailBuffer
+		err := p.runOutput(ctx, name, args, env, &output)
+		if err == nil || err == errCancelled || err == errTimedOut {
+			return err
+		}
+
+		class := classifyFailure(output.Bytes())
+		fmt.Printf("==> Attempt %d/%d failed with a %s: %v\n", attempt, attempts, class, err)
+		if !class.transient() || attempt >= attempts {
+			return fmt.Errorf("%s: %v", class, err)
+		}
+		fmt.Printf("==> Retrying in %s\n", delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return runErr(ctx)
+		}
+		delay *= 2
+	}
+}
+
+// tailBuffer keeps the last 64 KiB written to it. It is shared by the
+// stdout and stderr copies of the command, which write concurrently.
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+const tailSize = 64 * 1024
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n, err := t.buf.Write(p)
+	if extra := t.buf.Len() - tailSize; extra > 0 {
+		t.buf.Next(extra)
+	}
+	return n, err
+}
+
+// Bytes returns the content of the buffer.
+func (t *tailBuffer) Bytes() []byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.buf.Bytes()
+}
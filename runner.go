@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// Runner runs the commands of the analysis, such as sonar-scanner.
+type Runner interface {
+	// Run runs the command with env added to the environment of the plugin.
+	// When ctx is done, it stops the command and returns errCancelled or
+	// errTimedOut.
+	Run(ctx context.Context, name string, args, env []string, stdout, stderr io.Writer) error
+}
+
+// runner returns Plugin.Runner, or the runner of child processes.
+func (p Plugin) runner() (Runner, error) {
+	if p.Runner != nil {
+		return p.Runner, nil
+	}
+	grace, err := seconds(p.Config.GracePeriod)
+	if err != nil {
+		return nil, fmt.Errorf("invalid grace period: %v", err)
+	}
+	return execRunner{grace: grace}, nil
+}
+
+// execRunner runs commands as child processes. Cancellation signals are
+// forwarded to them, and they are killed after the grace period.
+type execRunner struct {
+	grace time.Duration
+}
+
+func (r execRunner) Run(ctx context.Context, name string, args, env []string, stdout, stderr io.Writer) error {
+	cmd := exec.Command(name, args...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+
+	if err := signalGroup(cmd, forwardedSignal(ctx)); err != nil {
+		killGroup(cmd)
+	}
+	select {
+	case <-done:
+	case <-time.After(r.grace):
+		fmt.Printf("==> %s still running after %s, killing it\n", name, r.grace)
+		killGroup(cmd)
+		<-done
+	}
+	return runErr(ctx)
+}
@@ -0,0 +1,243 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aosapps/drone-sonar-plugin/sonartest"
+)
+
+// syncBuffer is a buffer written by the stdout and stderr copies of a
+// command.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// waitFor waits until cond is true, failing the test after 10s.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// groupScript starts a child process then waits, each process printing the
+// SIGTERM it traps. The child is in the process group of the script.
+const groupScript = `
+sh -c 'trap "echo child got TERM; exit 0" TERM; while :; do sleep 0.05; done' &
+trap "wait; echo script got TERM; exit 3" TERM
+echo ready
+while :; do sleep 0.05; done
+`
+
+// intScript prints the SIGINT it traps. Asynchronous children ignore SIGINT
+// in a non-interactive shell, so it has none.
+const intScript = `
+trap "echo script got INT; exit 3" INT
+echo ready
+while :; do sleep 0.05; done
+`
+
+func TestExecRunnerForwardsSignal(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		ctx    func() (context.Context, context.CancelFunc)
+		err    error
+		want   []string
+	}{
+		{
+			name:   "cancelled",
+			script: groupScript,
+			ctx:    func() (context.Context, context.CancelFunc) { return context.WithCancel(context.Background()) },
+			err:    errCancelled,
+			want:   []string{"child got TERM", "script got TERM"},
+		},
+		{
+			name:   "received signal",
+			script: intScript,
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx := context.WithValue(context.Background(), signalKey{}, &received{sig: syscall.SIGINT})
+				return context.WithCancel(ctx)
+			},
+			err:  errCancelled,
+			want: []string{"script got INT"},
+		},
+		{
+			name:   "timed out",
+			script: groupScript,
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), time.Second)
+			},
+			err:  errTimedOut,
+			want: []string{"child got TERM", "script got TERM"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := test.ctx()
+			defer cancel()
+
+			var out syncBuffer
+			done := make(chan error, 1)
+			start := time.Now()
+			go func() {
+				done <- execRunner{grace: time.Minute}.Run(ctx, "sh", []string{"-c", test.script}, nil, &out, &out)
+			}()
+			waitFor(t, "the script", func() bool { return strings.Contains(out.String(), "ready") })
+			if test.err == errCancelled {
+				cancel()
+			}
+
+			err := <-done
+			if err != test.err {
+				t.Errorf("got error %v, want %v", err, test.err)
+			}
+			for _, want := range test.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output %q does not contain %q", out.String(), want)
+				}
+			}
+			if elapsed := time.Since(start); elapsed > 30*time.Second {
+				t.Errorf("stopped after %s, want before the grace period", elapsed)
+			}
+		})
+	}
+}
+
+func TestExecRunnerKill(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var out syncBuffer
+	done := make(chan error, 1)
+	start := time.Now()
+	stdout := captureStdout(t, func() {
+		go func() {
+			// the ignored signals are inherited by the child processes
+			script := `trap "" TERM INT; echo ready; while :; do sleep 0.05; done`
+			done <- execRunner{grace: 200 * time.Millisecond}.Run(ctx, "sh", []string{"-c", script}, nil, &out, &out)
+		}()
+		waitFor(t, "the script", func() bool { return strings.Contains(out.String(), "ready") })
+		cancel()
+		if err := <-done; err != errCancelled {
+			t.Errorf("got error %v, want %v", err, errCancelled)
+		}
+	})
+
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond || elapsed > 30*time.Second {
+		t.Errorf("killed after %s, want after the grace period of 200ms", elapsed)
+	}
+	if want := "==> sh still running after 200ms, killing it\n"; stdout != want {
+		t.Errorf("got output %q, want %q", stdout, want)
+	}
+}
+
+func TestExecRunnerExitStatus(t *testing.T) {
+	var out syncBuffer
+	err := execRunner{grace: time.Second}.Run(context.Background(), "sh", []string{"-c", "echo $TOKEN; exit 3"}, []string{"TOKEN=secret"}, &out, &out)
+	if err == nil || err.Error() != "exit status 3" {
+		t.Errorf("got error %v, want exit status 3", err)
+	}
+	if out.String() != "secret\n" {
+		t.Errorf("got output %q, want the env", out.String())
+	}
+}
+
+// TestMainExitCode runs the plugin in a child process, cancelled by SIGTERM
+// or by runTimeout while the fake scanner runs.
+func TestMainExitCode(t *testing.T) {
+	installFakeScanner(t)
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := sonartest.NewServer()
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		runTimeout string
+		signal     bool
+		code       int
+		output     string
+	}{
+		{name: "cancelled", runTimeout: "0", signal: true, code: 130, output: "analysis cancelled"},
+		{name: "timed out", runTimeout: "1", code: 124, output: "analysis timed out"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := tempDir(t)
+			args := filepath.Join(dir, "args")
+			cmd := exec.Command(exe)
+			cmd.Dir = dir
+			cmd.Env = append(os.Environ(),
+				"FAKE_SONAR_PLUGIN=1",
+				"FAKE_SONAR_SCANNER_ARGS="+args,
+				"FAKE_SONAR_SCANNER_SLEEP=1m",
+				"DRONE_REPO=octocat/hello-world",
+				"PLUGIN_SONAR_HOST="+server.URL,
+				"PLUGIN_SONAR_TOKEN=0123456789abcdef",
+				"PLUGIN_PREFLIGHT=false",
+				"PLUGIN_QUALITYGATE=false",
+				"PLUGIN_RUNTIMEOUT="+test.runTimeout,
+				"PLUGIN_GRACEPERIOD=5",
+			)
+			var out syncBuffer
+			cmd.Stdout = &out
+			cmd.Stderr = &out
+			if err := cmd.Start(); err != nil {
+				t.Fatal(err)
+			}
+			defer cmd.Process.Kill()
+
+			if test.signal {
+				waitFor(t, "the scanner", func() bool {
+					_, err := os.Stat(args)
+					return err == nil
+				})
+				if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := cmd.Wait()
+			var exit *exec.ExitError
+			if !errors.As(err, &exit) || exit.ExitCode() != test.code {
+				t.Errorf("got %v, want exit status %d\n%s", err, test.code, out.String())
+			}
+			if !strings.Contains(out.String(), test.output) {
+				t.Errorf("output does not contain %q:\n%s", test.output, out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// scannerPath returns the sonar-scanner binary of Config.ScannerVersion,
+// downloading it from the mirror into the cache directory when missing.
+func (p Plugin) scannerPath(ctx context.Context) (string, error) {
+	version := p.Config.ScannerVersion
+	name := "sonar-scanner-" + version
+	bin := p.scannerBin()
+	if _, err := os.Stat(bin); err == nil {
+		fmt.Printf("==> Using cached %s\n", name)
+		return bin, nil
+	}
+
+	archive := "sonar-scanner-cli-" + version + ".zip"
+	archiveURL := strings.TrimRight(p.Config.ScannerMirror, "/") + "/" + archive
+	fmt.Printf("==> Downloading %s\n", archiveURL)
+
+	if err := os.MkdirAll(p.Config.ScannerCache, 0755); err != nil {
+		return "", err
+	}
+	tmp, err := ioutil.TempFile(p.Config.ScannerCache, archive)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	if err := download(ctx, archiveURL, io.MultiWriter(tmp, hash)); err != nil {
+		return "", err
+	}
+
+	checksum := p.Config.ScannerChecksum
+	if checksum == "" {
+		var buf strings.Builder
+		if err := download(ctx, archiveURL+".sha256", &buf); err != nil {
+			return "", fmt.Errorf("cannot get the checksum of %s: %v", archive, err)
+		}
+		checksum = buf.String()
+	}
+	fields := strings.Fields(checksum)
+	if len(fields) == 0 || !strings.EqualFold(fields[0], hex.EncodeToString(hash.Sum(nil))) {
+		return "", fmt.Errorf("checksum mismatch for %s", archive)
+	}
+
+	// extract next to the cache entry and rename it, so that an interrupted
+	// extraction is never used
+	dir, err := ioutil.TempDir(p.Config.ScannerCache, name)
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	if err := unzip(tmp.Name(), dir); err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(filepath.Join(dir, name, "bin", "sonar-scanner")); err != nil {
+		return "", fmt.Errorf("%s does not contain %s/bin/sonar-scanner", archive, name)
+	}
+	if err := os.Rename(filepath.Join(dir, name), filepath.Join(p.Config.ScannerCache, name)); err != nil && !os.IsExist(err) {
+		return "", err
+	}
+	return bin, nil
+}
+
+// scannerBin returns the sonar-scanner binary of Config.ScannerVersion in
+// the cache directory.
+func (p Plugin) scannerBin() string {
+	name := "sonar-scanner-" + p.Config.ScannerVersion
+	return filepath.Join(p.Config.ScannerCache, name, "bin", "sonar-scanner")
+}
+
+// download writes the content at url to w.
+func download(ctx context.Context, url string, w io.Writer) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 10 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// unzip extracts the archive into dir, keeping file modes.
+func unzip(archive, dir string) error {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		path := filepath.Join(dir, f.Name)
+		if !strings.HasPrefix(path, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return fmt.Errorf("invalid file path in archive: %s", f.Name)
+		}
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := extractFile(f, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractFile(f *zip.File, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, f.Mode()|0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, rc); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
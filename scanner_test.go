@@ -0,0 +1,241 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testScannerVersion = "4.6.2.2472"
+
+// zipArchive returns a zip archive of the files, by name.
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		h := &zip.FileHeader{Name: name, Method: zip.Deflate}
+		h.SetMode(0755)
+		f, err := w.CreateHeader(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// scannerMirror serves the files, by path, and records the requested paths.
+type scannerMirror struct {
+	*httptest.Server
+	paths []string
+}
+
+func newScannerMirror(files map[string][]byte) *scannerMirror {
+	m := &scannerMirror{}
+	m.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.paths = append(m.paths, r.URL.Path)
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	return m
+}
+
+func scannerConfig(t *testing.T, mirror string) Plugin {
+	config := testConfig(t)
+	config.ScannerVersion = testScannerVersion
+	config.ScannerMirror = mirror + "/dist/"
+	config.ScannerCache = tempDir(t)
+	return Plugin{Config: config}
+}
+
+// scannerArchive is a sonar-scanner archive of testScannerVersion.
+func scannerArchive(t *testing.T) []byte {
+	return zipArchive(t, map[string]string{
+		"sonar-scanner-" + testScannerVersion + "/bin/sonar-scanner":         "#!/bin/sh\n",
+		"sonar-scanner-" + testScannerVersion + "/lib/sonar-scanner-cli.jar": "jar",
+	})
+}
+
+func TestScannerPathCached(t *testing.T) {
+	mirror := newScannerMirror(nil)
+	defer mirror.Close()
+	p := scannerConfig(t, mirror.URL)
+	writeFiles(t, map[string]string{p.scannerBin(): "#!/bin/sh\n"})
+
+	got, err := p.scannerPath(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != p.scannerBin() {
+		t.Errorf("got %s, want %s", got, p.scannerBin())
+	}
+	if len(mirror.paths) != 0 {
+		t.Errorf("cached scanner downloaded: %v", mirror.paths)
+	}
+}
+
+func TestScannerPathDownload(t *testing.T) {
+	archive := scannerArchive(t)
+	const path = "/dist/sonar-scanner-cli-" + testScannerVersion + ".zip"
+	mirror := newScannerMirror(map[string][]byte{
+		path:             archive,
+		path + ".sha256": []byte(sha256Hex(archive) + "  sonar-scanner-cli-" + testScannerVersion + ".zip\n"),
+	})
+	defer mirror.Close()
+	p := scannerConfig(t, mirror.URL)
+
+	got, err := p.scannerPath(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != p.scannerBin() {
+		t.Errorf("got %s, want %s", got, p.scannerBin())
+	}
+	if info, err := os.Stat(got); err != nil || info.Mode()&0111 == 0 {
+		t.Errorf("scanner not extracted as an executable: %v", err)
+	}
+	if want := []string{path, path + ".sha256"}; !reflect.DeepEqual(mirror.paths, want) {
+		t.Errorf("got requests %v, want %v", mirror.paths, want)
+	}
+	entries, err := filepath.Glob(filepath.Join(p.Config.ScannerCache, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{filepath.Join(p.Config.ScannerCache, "sonar-scanner-"+testScannerVersion)}; !reflect.DeepEqual(entries, want) {
+		t.Errorf("got cache entries %v, want %v", entries, want)
+	}
+
+	// the next run uses the cache
+	mirror.paths = nil
+	if _, err := p.scannerPath(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(mirror.paths) != 0 {
+		t.Errorf("cached scanner downloaded again: %v", mirror.paths)
+	}
+}
+
+func TestScannerPathInvalid(t *testing.T) {
+	const archive = "sonar-scanner-cli-" + testScannerVersion + ".zip"
+	const path = "/dist/" + archive
+	valid := scannerArchive(t)
+
+	tests := []struct {
+		name     string
+		files    map[string][]byte
+		checksum string
+		err      string
+	}{
+		{
+			name:     "checksum mismatch",
+			files:    map[string][]byte{path: valid},
+			checksum: sha256Hex([]byte("other")),
+			err:      "checksum mismatch for " + archive,
+		},
+		{
+			name:  "checksum file mismatch",
+			files: map[string][]byte{path: valid, path + ".sha256": []byte(sha256Hex([]byte("other")))},
+			err:   "checksum mismatch for " + archive,
+		},
+		{
+			name:  "empty checksum file",
+			files: map[string][]byte{path: valid, path + ".sha256": nil},
+			err:   "checksum mismatch for " + archive,
+		},
+		{
+			name:  "missing checksum file",
+			files: map[string][]byte{path: valid},
+			err:   "cannot get the checksum of " + archive + ": GET {{mirror}}" + path + ".sha256: 404 Not Found",
+		},
+		{
+			name: "missing archive",
+			err:  "GET {{mirror}}" + path + ": 404 Not Found",
+		},
+		{
+			name: "archive without the scanner",
+			files: map[string][]byte{path: zipArchive(t, map[string]string{
+				"sonar-scanner-" + testScannerVersion + "/lib/sonar-scanner-cli.jar": "jar",
+			})},
+			checksum: "{{archive}}",
+			err:      archive + " does not contain sonar-scanner-" + testScannerVersion + "/bin/sonar-scanner",
+		},
+		{
+			name: "archive escaping the cache",
+			files: map[string][]byte{path: zipArchive(t, map[string]string{
+				"sonar-scanner-" + testScannerVersion + "/bin/sonar-scanner": "#!/bin/sh\n",
+				"../../sonar-scanner-evil":                                   "#!/bin/sh\n",
+			})},
+			checksum: "{{archive}}",
+			err:      "invalid file path in archive: ../../sonar-scanner-evil",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mirror := newScannerMirror(test.files)
+			defer mirror.Close()
+			p := scannerConfig(t, mirror.URL)
+			p.Config.ScannerChecksum = test.checksum
+			if test.checksum == "{{archive}}" {
+				p.Config.ScannerChecksum = sha256Hex(test.files[path])
+			}
+
+			_, err := p.scannerPath(context.Background())
+			want := strings.Replace(test.err, "{{mirror}}", mirror.URL, 1)
+			if err == nil || err.Error() != want {
+				t.Fatalf("got error %v, want %s", err, want)
+			}
+			if _, err := os.Stat(filepath.Join(filepath.Dir(p.Config.ScannerCache), "sonar-scanner-evil")); !os.IsNotExist(err) {
+				t.Errorf("file written outside the cache: %v", err)
+			}
+			entries, err := filepath.Glob(filepath.Join(p.Config.ScannerCache, "*"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 0 {
+				t.Errorf("files left in the cache: %v", entries)
+			}
+		})
+	}
+}
+
+func TestUnzipSlip(t *testing.T) {
+	for _, name := range []string{"../evil", "a/../../evil", "/../evil"} {
+		t.Run(name, func(t *testing.T) {
+			dir := chdir(t)
+			writeFiles(t, map[string]string{"archive.zip": string(zipArchive(t, map[string]string{name: "evil"}))})
+			out := filepath.Join(dir, "out")
+
+			err := unzip("archive.zip", out)
+			if want := "invalid file path in archive: " + name; err == nil || err.Error() != want {
+				t.Errorf("got error %v, want %s", err, want)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "evil")); !os.IsNotExist(err) {
+				t.Errorf("file written outside the directory: %v", err)
+			}
+		})
+	}
+}
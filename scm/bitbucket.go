@@ -0,0 +1,40 @@
+package scm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// bitbucketServer is the client of the Bitbucket Server REST API. Its server
+// is the base URL of the Bitbucket instance.
+type bitbucketServer struct {
+	*client
+}
+
+func (b *bitbucketServer) header() http.Header {
+	return http.Header{"Authorization": {"Bearer " + b.token}}
+}
+
+func (b *bitbucketServer) Comment(ctx context.Context, pullRequest int, marker, body string) error {
+	return fmt.Errorf("pull request comments are not supported on bitbucket server")
+}
+
+// bitbucketStates maps the commit status states to build states.
+var bitbucketStates = map[State]string{
+	Pending: "INPROGRESS",
+	Success: "SUCCESSFUL",
+	Failure: "FAILED",
+}
+
+func (b *bitbucketServer) Status(ctx context.Context, sha string, status Status) error {
+	in := map[string]string{
+		"state":       bitbucketStates[status.State],
+		"key":         status.Context,
+		"name":        status.Context,
+		"description": status.Description,
+		"url":         status.URL,
+	}
+	_, err := b.do(ctx, "POST", "/rest/build-status/1.0/commits/"+sha, b.header(), in, nil)
+	return err
+}
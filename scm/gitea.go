@@ -0,0 +1,43 @@
+package scm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// gitea is the client of the Gitea API.
+type gitea struct {
+	*client
+}
+
+func (g *gitea) header() http.Header {
+	return http.Header{"Authorization": {"token " + g.token}}
+}
+
+func (g *gitea) Comment(ctx context.Context, pullRequest int, marker, body string) error {
+	var comments []comment
+	path := fmt.Sprintf("/repos/%s/issues/%d/comments", g.repo, pullRequest)
+	if _, err := g.do(ctx, "GET", path, g.header(), nil, &comments); err != nil {
+		return err
+	}
+
+	in := map[string]string{"body": body}
+	if id, found := findComment(comments, marker); found {
+		_, err := g.do(ctx, "PATCH", fmt.Sprintf("/repos/%s/issues/comments/%d", g.repo, id), g.header(), in, nil)
+		return err
+	}
+	_, err := g.do(ctx, "POST", path, g.header(), in, nil)
+	return err
+}
+
+func (g *gitea) Status(ctx context.Context, sha string, status Status) error {
+	in := map[string]string{
+		"state":       string(status.State),
+		"context":     status.Context,
+		"description": status.Description,
+		"target_url":  status.URL,
+	}
+	_, err := g.do(ctx, "POST", fmt.Sprintf("/repos/%s/statuses/%s", g.repo, sha), g.header(), in, nil)
+	return err
+}
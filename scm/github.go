@@ -0,0 +1,51 @@
+package scm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// github is the client of the GitHub REST API.
+type github struct {
+	*client
+}
+
+func (g *github) header() http.Header {
+	return http.Header{"Authorization": {"token " + g.token}}
+}
+
+func (g *github) Comment(ctx context.Context, pullRequest int, marker, body string) error {
+	var id int64
+	found := false
+	for page := 1; !found; page++ {
+		var comments []comment
+		path := fmt.Sprintf("/repos/%s/issues/%d/comments?per_page=100&page=%d", g.repo, pullRequest, page)
+		if _, err := g.do(ctx, "GET", path, g.header(), nil, &comments); err != nil {
+			return err
+		}
+		if len(comments) == 0 {
+			break
+		}
+		id, found = findComment(comments, marker)
+	}
+
+	in := map[string]string{"body": body}
+	if found {
+		_, err := g.do(ctx, "PATCH", fmt.Sprintf("/repos/%s/issues/comments/%d", g.repo, id), g.header(), in, nil)
+		return err
+	}
+	_, err := g.do(ctx, "POST", fmt.Sprintf("/repos/%s/issues/%d/comments", g.repo, pullRequest), g.header(), in, nil)
+	return err
+}
+
+func (g *github) Status(ctx context.Context, sha string, status Status) error {
+	in := map[string]string{
+		"state":       string(status.State),
+		"context":     status.Context,
+		"description": status.Description,
+		"target_url":  status.URL,
+	}
+	_, err := g.do(ctx, "POST", fmt.Sprintf("/repos/%s/statuses/%s", g.repo, sha), g.header(), in, nil)
+	return err
+}
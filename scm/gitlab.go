@@ -0,0 +1,64 @@
+package scm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// gitlab is the client of the GitLab REST API. Pull requests are merge
+// requests, identified by their project internal ID.
+type gitlab struct {
+	*client
+}
+
+func (g *gitlab) header() http.Header {
+	return http.Header{"Private-Token": {g.token}}
+}
+
+func (g *gitlab) project() string {
+	return url.PathEscape(g.repo)
+}
+
+func (g *gitlab) Comment(ctx context.Context, pullRequest int, marker, body string) error {
+	notes := fmt.Sprintf("/projects/%s/merge_requests/%d/notes", g.project(), pullRequest)
+
+	var id int64
+	found := false
+	for page := "1"; page != "" && !found; {
+		var comments []comment
+		header, err := g.do(ctx, "GET", notes+"?per_page=100&page="+page, g.header(), nil, &comments)
+		if err != nil {
+			return err
+		}
+		id, found = findComment(comments, marker)
+		page = header.Get("X-Next-Page")
+	}
+
+	in := map[string]string{"body": body}
+	if found {
+		_, err := g.do(ctx, "PUT", fmt.Sprintf("%s/%d", notes, id), g.header(), in, nil)
+		return err
+	}
+	_, err := g.do(ctx, "POST", notes, g.header(), in, nil)
+	return err
+}
+
+// gitlabStates maps the commit status states to GitLab states.
+var gitlabStates = map[State]string{
+	Pending: "pending",
+	Success: "success",
+	Failure: "failed",
+}
+
+func (g *gitlab) Status(ctx context.Context, sha string, status Status) error {
+	in := map[string]string{
+		"state":       gitlabStates[status.State],
+		"name":        status.Context,
+		"description": status.Description,
+		"target_url":  status.URL,
+	}
+	_, err := g.do(ctx, "POST", fmt.Sprintf("/projects/%s/statuses/%s", g.project(), sha), g.header(), in, nil)
+	return err
+}
@@ -0,0 +1,147 @@
+// Package scm posts analysis results to source code management systems.
+package scm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Client posts analysis results to a pull request or a commit.
+type Client interface {
+	// Comment creates the pull request comment, or updates the existing
+	// comment containing marker.
+	Comment(ctx context.Context, pullRequest int, marker, body string) error
+
+	// Status sets the status of a commit.
+	Status(ctx context.Context, sha string, status Status) error
+}
+
+// State is the state of a commit status.
+type State string
+
+// Commit status states.
+const (
+	Pending State = "pending"
+	Success State = "success"
+	Failure State = "failure"
+)
+
+// Status is a commit status, identified by its context.
+type Status struct {
+	State       State
+	Context     string
+	Description string
+	URL         string
+}
+
+// New returns the client of the provider for the repository owner/name.
+// server is the base URL of the provider API.
+func New(provider, server, token, repo string) (Client, error) {
+	if strings.Count(repo, "/") < 1 {
+		return nil, fmt.Errorf("invalid repository %q, expected owner/name", repo)
+	}
+	if token == "" {
+		return nil, fmt.Errorf("missing %s token", provider)
+	}
+	c := &client{
+		server: strings.TrimRight(server, "/"),
+		token:  token,
+		repo:   repo,
+		http:   &http.Client{Timeout: time.Minute},
+	}
+
+	switch provider {
+	case "github":
+		if c.server == "" {
+			c.server = "https://api.github.com"
+		}
+		return &github{c}, nil
+	case "gitea":
+		if c.server == "" {
+			return nil, fmt.Errorf("missing gitea server")
+		}
+		return &gitea{c}, nil
+	case "gitlab":
+		if c.server == "" {
+			c.server = "https://gitlab.com/api/v4"
+		}
+		return &gitlab{c}, nil
+	case "bitbucket-server":
+		if c.server == "" {
+			return nil, fmt.Errorf("missing bitbucket server")
+		}
+		return &bitbucketServer{c}, nil
+	}
+	return nil, fmt.Errorf("unsupported scm provider %q, expected github, gitea, gitlab or bitbucket-server", provider)
+}
+
+// client is the HTTP transport shared by the providers.
+type client struct {
+	server string
+	token  string
+	repo   string
+	http   *http.Client
+}
+
+// do sends the JSON encoding of in with the given headers and decodes the
+// response into out. It returns the response headers.
+func (c *client) do(ctx context.Context, method, path string, header http.Header, in, out interface{}) (http.Header, error) {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.server+path, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("%s %s: %s %s", method, path, resp.Status, bytes.TrimSpace(msg))
+	}
+	if out == nil {
+		return resp.Header, nil
+	}
+	return resp.Header, json.NewDecoder(resp.Body).Decode(out)
+}
+
+// comment is a pull request comment of any provider.
+type comment struct {
+	ID   int64  `json:"id"`
+	Body string `json:"body"`
+}
+
+// findComment returns the ID of the first comment containing marker.
+func findComment(comments []comment, marker string) (int64, bool) {
+	for _, c := range comments {
+		if strings.Contains(c.Body, marker) {
+			return c.ID, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,320 @@
+package scm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const marker = "<!-- sonar -->"
+
+// request is a request received by the fake provider.
+type request struct {
+	Method string
+	URL    string
+	Body   map[string]string
+}
+
+// provider is a fake provider API replying with the routes, by method and
+// escaped URL, and recording the requests.
+type provider struct {
+	*httptest.Server
+	requests []request
+	headers  []http.Header
+}
+
+// route is a response of the fake provider.
+type route struct {
+	header http.Header
+	body   interface{}
+}
+
+func newProvider(routes map[string]route) *provider {
+	p := &provider{}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u := r.URL.EscapedPath()
+		if r.URL.RawQuery != "" {
+			u += "?" + r.URL.RawQuery
+		}
+		req := request{Method: r.Method, URL: u}
+		json.NewDecoder(r.Body).Decode(&req.Body)
+		p.requests = append(p.requests, req)
+		p.headers = append(p.headers, r.Header)
+
+		res, ok := routes[r.Method+" "+u]
+		if !ok {
+			res = route{body: map[string]string{}}
+			if r.Method == "GET" {
+				res.body = []comment{}
+			}
+		}
+		for k, v := range res.header {
+			w.Header()[k] = v
+		}
+		json.NewEncoder(w).Encode(res.body)
+	}))
+	return p
+}
+
+// comments returns n comments without the marker, with IDs from first.
+func comments(first, n int) []comment {
+	var list []comment
+	for i := 0; i < n; i++ {
+		list = append(list, comment{ID: int64(first + i), Body: fmt.Sprintf("comment %d", first+i)})
+	}
+	return list
+}
+
+func TestComment(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		repo     string
+		routes   map[string]route
+		want     []string
+		err      string
+	}{
+		{
+			name:     "github marker on the second page",
+			provider: "github",
+			repo:     "octocat/hello-world",
+			routes: map[string]route{
+				"GET /repos/octocat/hello-world/issues/7/comments?per_page=100&page=1": {body: comments(1, 100)},
+				"GET /repos/octocat/hello-world/issues/7/comments?per_page=100&page=2": {body: append(comments(101, 3), comment{ID: 142, Body: marker + "\nold"})},
+			},
+			want: []string{
+				"GET /repos/octocat/hello-world/issues/7/comments?per_page=100&page=1",
+				"GET /repos/octocat/hello-world/issues/7/comments?per_page=100&page=2",
+				"PATCH /repos/octocat/hello-world/issues/comments/142",
+			},
+		},
+		{
+			name:     "github without marker",
+			provider: "github",
+			repo:     "octocat/hello-world",
+			routes: map[string]route{
+				"GET /repos/octocat/hello-world/issues/7/comments?per_page=100&page=1": {body: comments(1, 100)},
+			},
+			want: []string{
+				"GET /repos/octocat/hello-world/issues/7/comments?per_page=100&page=1",
+				"GET /repos/octocat/hello-world/issues/7/comments?per_page=100&page=2",
+				"POST /repos/octocat/hello-world/issues/7/comments",
+			},
+		},
+		{
+			name:     "gitea marker",
+			provider: "gitea",
+			repo:     "octocat/hello-world",
+			routes: map[string]route{
+				"GET /repos/octocat/hello-world/issues/7/comments": {body: append(comments(1, 2), comment{ID: 3, Body: marker})},
+			},
+			want: []string{
+				"GET /repos/octocat/hello-world/issues/7/comments",
+				"PATCH /repos/octocat/hello-world/issues/comments/3",
+			},
+		},
+		{
+			name:     "gitea without marker",
+			provider: "gitea",
+			repo:     "octocat/hello-world",
+			want: []string{
+				"GET /repos/octocat/hello-world/issues/7/comments",
+				"POST /repos/octocat/hello-world/issues/7/comments",
+			},
+		},
+		{
+			name:     "gitlab marker on the next page",
+			provider: "gitlab",
+			repo:     "group/subgroup/project",
+			routes: map[string]route{
+				"GET /projects/group%2Fsubgroup%2Fproject/merge_requests/7/notes?per_page=100&page=1": {
+					header: http.Header{"X-Next-Page": {"2"}},
+					body:   comments(1, 100),
+				},
+				"GET /projects/group%2Fsubgroup%2Fproject/merge_requests/7/notes?per_page=100&page=2": {
+					header: http.Header{"X-Next-Page": {"3"}},
+					body:   []comment{{ID: 120, Body: marker}},
+				},
+			},
+			want: []string{
+				"GET /projects/group%2Fsubgroup%2Fproject/merge_requests/7/notes?per_page=100&page=1",
+				"GET /projects/group%2Fsubgroup%2Fproject/merge_requests/7/notes?per_page=100&page=2",
+				"PUT /projects/group%2Fsubgroup%2Fproject/merge_requests/7/notes/120",
+			},
+		},
+		{
+			name:     "gitlab without marker",
+			provider: "gitlab",
+			repo:     "group/project",
+			routes: map[string]route{
+				"GET /projects/group%2Fproject/merge_requests/7/notes?per_page=100&page=1": {
+					header: http.Header{"X-Next-Page": {""}},
+					body:   comments(1, 2),
+				},
+			},
+			want: []string{
+				"GET /projects/group%2Fproject/merge_requests/7/notes?per_page=100&page=1",
+				"POST /projects/group%2Fproject/merge_requests/7/notes",
+			},
+		},
+		{
+			name:     "bitbucket server",
+			provider: "bitbucket-server",
+			repo:     "PROJ/repo",
+			err:      "pull request comments are not supported on bitbucket server",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := newProvider(test.routes)
+			defer p.Close()
+			c, err := New(test.provider, p.URL, "token", test.repo)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = c.Comment(context.Background(), 7, marker, marker+"\nnew")
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("got error %v, want %s", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, r := range p.requests {
+				got = append(got, r.Method+" "+r.URL)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got requests\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+			}
+			last := p.requests[len(p.requests)-1]
+			if want := map[string]string{"body": marker + "\nnew"}; !reflect.DeepEqual(last.Body, want) {
+				t.Errorf("got body %v, want %v", last.Body, want)
+			}
+		})
+	}
+}
+
+func TestCommentError(t *testing.T) {
+	p := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"message":"Resource not accessible by integration"}`)
+	}))
+	defer p.Close()
+	c, err := New("github", p.URL, "token", "octocat/hello-world")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.Comment(context.Background(), 7, marker, marker)
+	want := `GET /repos/octocat/hello-world/issues/7/comments?per_page=100&page=1: 403 Forbidden {"message":"Resource not accessible by integration"}`
+	if err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		provider, server, token, repo string
+		err                           string
+	}{
+		{"github", "", "token", "octocat", `invalid repository "octocat", expected owner/name`},
+		{"github", "", "", "octocat/hello-world", "missing github token"},
+		{"gitea", "", "token", "octocat/hello-world", "missing gitea server"},
+		{"bitbucket-server", "", "token", "PROJ/repo", "missing bitbucket server"},
+		{"svn", "", "token", "octocat/hello-world", `unsupported scm provider "svn", expected github, gitea, gitlab or bitbucket-server`},
+	}
+	for _, test := range tests {
+		_, err := New(test.provider, test.server, test.token, test.repo)
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%s: got error %v, want %s", test.provider, err, test.err)
+		}
+	}
+}
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		provider, repo, url, header, auth string
+		states                            map[State]string
+	}{
+		{
+			provider: "github",
+			repo:     "octocat/hello-world",
+			url:      "/repos/octocat/hello-world/statuses/6dcb09b",
+			header:   "Authorization",
+			auth:     "token secret",
+			states:   map[State]string{Pending: "pending", Success: "success", Failure: "failure"},
+		},
+		{
+			provider: "gitea",
+			repo:     "octocat/hello-world",
+			url:      "/repos/octocat/hello-world/statuses/6dcb09b",
+			header:   "Authorization",
+			auth:     "token secret",
+			states:   map[State]string{Pending: "pending", Success: "success", Failure: "failure"},
+		},
+		{
+			provider: "gitlab",
+			repo:     "group/project",
+			url:      "/projects/group%2Fproject/statuses/6dcb09b",
+			header:   "Private-Token",
+			auth:     "secret",
+			states:   map[State]string{Pending: "pending", Success: "success", Failure: "failed"},
+		},
+		{
+			provider: "bitbucket-server",
+			repo:     "PROJ/repo",
+			url:      "/rest/build-status/1.0/commits/6dcb09b",
+			header:   "Authorization",
+			auth:     "Bearer secret",
+			states:   map[State]string{Pending: "INPROGRESS", Success: "SUCCESSFUL", Failure: "FAILED"},
+		},
+	}
+
+	for _, test := range tests {
+		for state, want := range test.states {
+			t.Run(test.provider+"/"+string(state), func(t *testing.T) {
+				p := newProvider(nil)
+				defer p.Close()
+				c, err := New(test.provider, p.URL, "secret", test.repo)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				err = c.Status(context.Background(), "6dcb09b", Status{
+					State:       state,
+					Context:     "sonarqube",
+					Description: "Quality gate passed",
+					URL:         "https://sonar.example.com/dashboard?id=hello-world",
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if len(p.requests) != 1 {
+					t.Fatalf("got %d requests, want 1", len(p.requests))
+				}
+				r := p.requests[0]
+				if got := r.Method + " " + r.URL; got != "POST "+test.url {
+					t.Errorf("got request %s, want POST %s", got, test.url)
+				}
+				if got := r.Body["state"]; got != want {
+					t.Errorf("got state %q, want %q", got, want)
+				}
+				if got := p.headers[0].Get(test.header); got != test.auth {
+					t.Errorf("got %s header %q, want %q", test.header, got, test.auth)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+// Server describes the SonarQube server receiving the analysis.
+type Server struct {
+	Version         string
+	Edition         string
+	BranchesEnabled bool
+}
+
+// detectServer returns the version and edition of the server.
+func (p Plugin) detectServer(ctx context.Context) (*Server, error) {
+	version, err := p.api.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+	global, err := p.api.Global(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{
+		Version:         version,
+		Edition:         global.Edition,
+		BranchesEnabled: global.BranchesEnabled,
+	}, nil
+}
+
+// atLeast reports whether the server version is major.minor or later. An
+// unknown version is considered older.
+func (s *Server) atLeast(major, minor int) bool {
+	parts := strings.SplitN(s.Version, ".", 3)
+	if len(parts) < 2 {
+		return false
+	}
+	ma, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return false
+	}
+	mi, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return false
+	}
+	return ma > major || ma == major && mi >= minor
+}
+
+// supportsBranches reports whether branch and pull request analyses are
+// available, with a commercial edition or the community branch plugin. An
+// unknown edition is assumed to support them.
+func (s *Server) supportsBranches() bool {
+	return s.Edition != "community" || s.BranchesEnabled
+}
+
+// tokenProperties returns the properties passing the token to the scanner.
+// SonarQube 10.0 deprecates sonar.login for sonar.token, which scanners
+// before sonar-scanner 5.0, such as the one of the image, and older maven
+// and gradle plugins do not read. Both are passed then, unless the scanner
+// is known to read sonar.token.
+func (p Plugin) tokenProperties(server *Server) []string {
+	if !server.atLeast(10, 0) {
+		return []string{"sonar.login"}
+	}
+	if p.Config.Mode == "" || p.Config.Mode == modeCLI {
+		scanner := &Server{Version: p.Config.ScannerVersion}
+		if scanner.atLeast(5, 0) {
+			return []string{"sonar.token"}
+		}
+	}
+	return []string{"sonar.login", "sonar.token"}
+}
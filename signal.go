@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+var (
+	errCancelled = errors.New("analysis cancelled")
+	errTimedOut  = errors.New("analysis timed out")
+)
+
+type signalKey struct{}
+
+// received holds the signal which cancelled the run.
+type received struct {
+	sync.Mutex
+	sig os.Signal
+}
+
+// runContext returns the context of the run. It is cancelled on SIGINT or
+// SIGTERM, and after Config.RunTimeout seconds unless it is 0.
+func (p Plugin) runContext() (context.Context, context.CancelFunc, error) {
+	timeout, err := seconds(p.Config.RunTimeout)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid run timeout: %v", err)
+	}
+
+	r := &received{}
+	ctx := context.WithValue(context.Background(), signalKey{}, r)
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-signals:
+			fmt.Printf("==> Received %s, stopping the analysis\n", sig)
+			r.Lock()
+			r.sig = sig
+			r.Unlock()
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel()
+	}, nil
+}
+
+// runErr returns the error reporting why the context is done.
+func runErr(ctx context.Context) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return errTimedOut
+	}
+	return errCancelled
+}
+
+// runError returns errCancelled or errTimedOut when err is caused by the end
+// of the run context, err otherwise.
+func runError(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil || errors.Is(err, errCancelled) || errors.Is(err, errTimedOut) {
+		return err
+	}
+	return runErr(ctx)
+}
+
+// forwardedSignal returns the signal received by the plugin, or SIGTERM
+// when the run timed out.
+func forwardedSignal(ctx context.Context) os.Signal {
+	if r, ok := ctx.Value(signalKey{}).(*received); ok {
+		r.Lock()
+		defer r.Unlock()
+		if r.sig != nil {
+			return r.sig
+		}
+	}
+	return syscall.SIGTERM
+}
@@ -0,0 +1,264 @@
+package sonar
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+type (
+	// SystemStatus is the state of the server.
+	SystemStatus struct {
+		ID      string `json:"id"`
+		Version string `json:"version"`
+		Status  string `json:"status"`
+	}
+
+	// Global is the global configuration of the server.
+	Global struct {
+		Edition         string `json:"edition"`
+		BranchesEnabled bool   `json:"branchesEnabled"`
+	}
+
+	// User is the user of the token.
+	User struct {
+		Login       string `json:"login"`
+		IsLoggedIn  bool   `json:"isLoggedIn"`
+		Permissions struct {
+			Global []string `json:"global"`
+		} `json:"permissions"`
+	}
+
+	// Component is a project, directory or file.
+	Component struct {
+		Key  string `json:"key"`
+		Name string `json:"name"`
+	}
+
+	// PermissionUser is a user with its permissions on a project.
+	PermissionUser struct {
+		Login       string   `json:"login"`
+		Permissions []string `json:"permissions"`
+	}
+
+	// Task is a background task, such as the processing of an analysis
+	// report.
+	Task struct {
+		ID           string `json:"id"`
+		Status       string `json:"status"`
+		AnalysisID   string `json:"analysisId"`
+		ErrorMessage string `json:"errorMessage"`
+	}
+
+	// ProjectStatus is the status of the project quality gate for an
+	// analysis.
+	ProjectStatus struct {
+		Status     string      `json:"status"`
+		Conditions []Condition `json:"conditions"`
+	}
+
+	// Condition is a single condition of the quality gate.
+	Condition struct {
+		Status         string `json:"status"`
+		MetricKey      string `json:"metricKey"`
+		Comparator     string `json:"comparator"`
+		ErrorThreshold string `json:"errorThreshold"`
+		ActualValue    string `json:"actualValue"`
+	}
+
+	// Measure is the value of a metric. Measures of the new code period
+	// have the value of the period.
+	Measure struct {
+		Metric string
+		Value  string
+	}
+
+	// Issue is an issue found by an analysis.
+	Issue struct {
+		Key       string `json:"key"`
+		Rule      string `json:"rule"`
+		Severity  string `json:"severity"`
+		Component string `json:"component"`
+		Line      int    `json:"line"`
+		Message   string `json:"message"`
+		Type      string `json:"type"`
+	}
+)
+
+// SystemStatus returns the state of the server, UP once it is ready.
+func (c *Client) SystemStatus(ctx context.Context) (*SystemStatus, error) {
+	status := &SystemStatus{}
+	return status, c.Get(ctx, "api/system/status", nil, status)
+}
+
+// Version returns the version of the server.
+func (c *Client) Version(ctx context.Context) (string, error) {
+	var version string
+	return version, c.Get(ctx, "api/server/version", nil, &version)
+}
+
+// Global returns the global configuration of the server.
+func (c *Client) Global(ctx context.Context) (*Global, error) {
+	global := &Global{}
+	return global, c.Get(ctx, "api/navigation/global", nil, global)
+}
+
+// ValidateToken reports whether the token is valid.
+func (c *Client) ValidateToken(ctx context.Context) (bool, error) {
+	var res struct {
+		Valid bool `json:"valid"`
+	}
+	return res.Valid, c.Get(ctx, "api/authentication/validate", nil, &res)
+}
+
+// CurrentUser returns the user of the token.
+func (c *Client) CurrentUser(ctx context.Context) (*User, error) {
+	user := &User{}
+	return user, c.Get(ctx, "api/users/current", nil, user)
+}
+
+// Component returns the component key, or an *Error with the 404 status
+// code when it does not exist.
+func (c *Client) Component(ctx context.Context, key string) (*Component, error) {
+	component := &Component{}
+	return component, c.Get(ctx, "api/navigation/component", url.Values{"component": {key}}, component)
+}
+
+// PermissionUsers returns the users matching q with the permission on the
+// project.
+func (c *Client) PermissionUsers(ctx context.Context, projectKey, permission, q string) ([]PermissionUser, error) {
+	query := url.Values{"projectKey": {projectKey}, "permission": {permission}, "q": {q}}
+	var users []PermissionUser
+	for p := 1; ; p++ {
+		var res struct {
+			Paging Paging           `json:"paging"`
+			Users  []PermissionUser `json:"users"`
+		}
+		if err := c.Get(ctx, "api/permissions/users", page(query, p), &res); err != nil {
+			return nil, err
+		}
+		users = append(users, res.Users...)
+		if !res.Paging.more(len(users), len(res.Users)) {
+			return users, nil
+		}
+	}
+}
+
+// SearchProjects returns the existing projects among keys.
+func (c *Client) SearchProjects(ctx context.Context, keys ...string) ([]Component, error) {
+	query := url.Values{"projects": {strings.Join(keys, ",")}}
+	var projects []Component
+	for p := 1; ; p++ {
+		var res struct {
+			Paging     Paging      `json:"paging"`
+			Components []Component `json:"components"`
+		}
+		if err := c.Get(ctx, "api/projects/search", page(query, p), &res); err != nil {
+			return nil, err
+		}
+		projects = append(projects, res.Components...)
+		if !res.Paging.more(len(projects), len(res.Components)) {
+			return projects, nil
+		}
+	}
+}
+
+// CreateProject creates the project key.
+func (c *Client) CreateProject(ctx context.Context, key, name string) error {
+	return c.Post(ctx, "api/projects/create", url.Values{"project": {key}, "name": {name}}, nil)
+}
+
+// ApplyTemplate applies the permission template to the project.
+func (c *Client) ApplyTemplate(ctx context.Context, projectKey, template string) error {
+	return c.Post(ctx, "api/permissions/apply_template", url.Values{"projectKey": {projectKey}, "templateName": {template}}, nil)
+}
+
+// SelectQualityGate sets the quality gate of the project.
+func (c *Client) SelectQualityGate(ctx context.Context, projectKey, gate string) error {
+	return c.Post(ctx, "api/qualitygates/select", url.Values{"projectKey": {projectKey}, "gateName": {gate}}, nil)
+}
+
+// AddQualityProfile sets the quality profile of the project for the
+// language.
+func (c *Client) AddQualityProfile(ctx context.Context, projectKey, language, profile string) error {
+	form := url.Values{"project": {projectKey}, "language": {language}, "qualityProfile": {profile}}
+	return c.Post(ctx, "api/qualityprofiles/add_project", form, nil)
+}
+
+// Task returns the background task id.
+func (c *Client) Task(ctx context.Context, id string) (*Task, error) {
+	var res struct {
+		Task Task `json:"task"`
+	}
+	return &res.Task, c.Get(ctx, "api/ce/task", url.Values{"id": {id}}, &res)
+}
+
+// ProjectStatus returns the quality gate status of the analysis.
+func (c *Client) ProjectStatus(ctx context.Context, analysisID string) (*ProjectStatus, error) {
+	var res struct {
+		ProjectStatus ProjectStatus `json:"projectStatus"`
+	}
+	return &res.ProjectStatus, c.Get(ctx, "api/qualitygates/project_status", url.Values{"analysisId": {analysisID}}, &res)
+}
+
+// Measures returns the measures of the metrics for the component, selected
+// by the component, branch and pullRequest parameters of query.
+func (c *Client) Measures(ctx context.Context, query url.Values, metrics []string) ([]Measure, error) {
+	q := url.Values{"metricKeys": {strings.Join(metrics, ",")}}
+	for k, v := range query {
+		q[k] = v
+	}
+	var res struct {
+		Component struct {
+			Measures []struct {
+				Metric string `json:"metric"`
+				Value  string `json:"value"`
+				Period *struct {
+					Value string `json:"value"`
+				} `json:"period"`
+				Periods []struct {
+					Value string `json:"value"`
+				} `json:"periods"`
+			} `json:"measures"`
+		} `json:"component"`
+	}
+	if err := c.Get(ctx, "api/measures/component", q, &res); err != nil {
+		return nil, err
+	}
+
+	var measures []Measure
+	for _, m := range res.Component.Measures {
+		switch {
+		case m.Value != "":
+			measures = append(measures, Measure{m.Metric, m.Value})
+		case m.Period != nil:
+			measures = append(measures, Measure{m.Metric, m.Period.Value})
+		case len(m.Periods) > 0:
+			measures = append(measures, Measure{m.Metric, m.Periods[0].Value})
+		}
+	}
+	return measures, nil
+}
+
+// Issues returns the open issues of the component, selected by the
+// componentKeys, branch and pullRequest parameters of query.
+func (c *Client) Issues(ctx context.Context, query url.Values) ([]Issue, error) {
+	q := url.Values{"resolved": {"false"}}
+	for k, v := range query {
+		q[k] = v
+	}
+	var issues []Issue
+	for p := 1; ; p++ {
+		var res struct {
+			Paging Paging  `json:"paging"`
+			Issues []Issue `json:"issues"`
+		}
+		if err := c.Get(ctx, "api/issues/search", page(q, p), &res); err != nil {
+			return nil, err
+		}
+		issues = append(issues, res.Issues...)
+		if !res.Paging.more(len(issues), len(res.Issues)) {
+			return issues, nil
+		}
+	}
+}
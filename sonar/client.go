@@ -0,0 +1,176 @@
+// Package sonar is a client of the SonarQube Web API.
+package sonar
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client calls the Web API of a SonarQube server, authenticated with a
+// user or analysis token.
+type Client struct {
+	// Retries is how many times a rate limited request, or a GET request
+	// failing with a server error, is sent again.
+	Retries int
+	// Backoff is the delay before the first retry, doubled for each
+	// following one. A Retry-After header takes precedence.
+	Backoff time.Duration
+
+	server string
+	token  string
+	http   *http.Client
+}
+
+// New returns a client of the server at the base URL server, with requests
+// timing out after timeout.
+func New(server, token string, timeout time.Duration) *Client {
+	return &Client{
+		Retries: 3,
+		Backoff: time.Second,
+		server:  strings.TrimRight(server, "/"),
+		token:   token,
+		http:    &http.Client{Timeout: timeout},
+	}
+}
+
+// Error is an error response of the Web API.
+type Error struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Status     string
+	Messages   []string
+}
+
+func (e *Error) Error() string {
+	if len(e.Messages) == 0 {
+		return fmt.Sprintf("%s %s: %s", e.Method, e.Path, e.Status)
+	}
+	return fmt.Sprintf("%s %s: %s: %s", e.Method, e.Path, e.Status, strings.Join(e.Messages, ", "))
+}
+
+// Get calls path and decodes the JSON response into v. When v is a
+// *string, it is set to the plain text response.
+func (c *Client) Get(ctx context.Context, path string, query url.Values, v interface{}) error {
+	return c.call(ctx, "GET", path, query, v)
+}
+
+// Post sends the form to path. The response is decoded into v unless it
+// is nil.
+func (c *Client) Post(ctx context.Context, path string, form url.Values, v interface{}) error {
+	return c.call(ctx, "POST", path, form, v)
+}
+
+func (c *Client) call(ctx context.Context, method, path string, params url.Values, v interface{}) error {
+	delay := c.Backoff
+	for attempt := 0; ; attempt++ {
+		resp, err := c.do(ctx, method, path, params)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+			defer resp.Body.Close()
+			return decode(resp, v)
+		}
+
+		apiErr := readError(method, path, resp)
+		retry := resp.StatusCode == http.StatusTooManyRequests ||
+			resp.StatusCode >= 500 && method == "GET"
+		if !retry || attempt >= c.Retries {
+			return apiErr
+		}
+		wait := delay
+		if after, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			wait = time.Duration(after) * time.Second
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		delay *= 2
+	}
+}
+
+func (c *Client) do(ctx context.Context, method, path string, params url.Values) (*http.Response, error) {
+	u := c.server + "/" + strings.TrimPrefix(path, "/")
+	var body io.Reader
+	if method == "GET" && len(params) > 0 {
+		u += "?" + params.Encode()
+	} else if method != "GET" {
+		body = strings.NewReader(params.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(c.token, "")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	return c.http.Do(req)
+}
+
+// readError reads the errors[].msg messages of an error response.
+func readError(method, path string, resp *http.Response) error {
+	defer resp.Body.Close()
+	var res struct {
+		Errors []struct {
+			Msg string `json:"msg"`
+		} `json:"errors"`
+	}
+	apiErr := &Error{Method: method, Path: path, StatusCode: resp.StatusCode, Status: resp.Status}
+	if json.NewDecoder(resp.Body).Decode(&res) == nil {
+		for _, e := range res.Errors {
+			apiErr.Messages = append(apiErr.Messages, e.Msg)
+		}
+	}
+	return apiErr
+}
+
+func decode(resp *http.Response, v interface{}) error {
+	if v == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if text, ok := v.(*string); ok {
+		data, err := ioutil.ReadAll(resp.Body)
+		*text = strings.TrimSpace(string(data))
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// pageSize is the largest page size of the search endpoints.
+const pageSize = 500
+
+// Paging is the pagination of a search response.
+type Paging struct {
+	PageIndex int `json:"pageIndex"`
+	PageSize  int `json:"pageSize"`
+	Total     int `json:"total"`
+}
+
+// more reports whether another page follows, read items being read so
+// far and n in the last page.
+func (p Paging) more(read, n int) bool {
+	return n > 0 && read < p.Total
+}
+
+// page returns a copy of the query asking for the page p.
+func page(query url.Values, p int) url.Values {
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	q.Set("p", strconv.Itoa(p))
+	q.Set("ps", strconv.Itoa(pageSize))
+	return q
+}
@@ -0,0 +1,191 @@
+package sonar
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/aosapps/drone-sonar-plugin/sonartest"
+)
+
+func newClient(server *sonartest.Server) *Client {
+	c := New(server.URL+"/", "0123456789abcdef", time.Minute)
+	c.Backoff = time.Millisecond
+	return c
+}
+
+func TestAuth(t *testing.T) {
+	server := sonartest.NewServer()
+	defer server.Close()
+	server.Token = "0123456789abcdef"
+
+	if _, err := newClient(server).SystemStatus(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	server.Token = "other"
+	_, err := newClient(server).SystemStatus(context.Background())
+	if apiErr, ok := err.(*Error); !ok || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("got error %v, want 401", err)
+	}
+}
+
+func TestTask(t *testing.T) {
+	server := sonartest.NewServer()
+	defer server.Close()
+	server.Task("AXtask", "AXanalysis", "PENDING", "SUCCESS")
+	c := newClient(server)
+
+	task, err := c.Task(context.Background(), "AXtask")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Status != "PENDING" || task.AnalysisID != "" {
+		t.Errorf("got task %+v, want PENDING", task)
+	}
+	task, err = c.Task(context.Background(), "AXtask")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Status != "SUCCESS" || task.AnalysisID != "AXanalysis" {
+		t.Errorf("got task %+v, want SUCCESS", task)
+	}
+}
+
+func TestMeasures(t *testing.T) {
+	server := sonartest.NewServer()
+	defer server.Close()
+	server.Measures("octocat:hello-world", map[string]string{"bugs": "3", "new_coverage": "90.0"})
+
+	measures, err := newClient(server).Measures(context.Background(), url.Values{"component": {"octocat:hello-world"}}, []string{"bugs", "new_coverage"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]string{}
+	for _, m := range measures {
+		values[m.Metric] = m.Value
+	}
+	if values["bugs"] != "3" || values["new_coverage"] != "90.0" {
+		t.Errorf("got measures %v", values)
+	}
+	if got := server.Requests()[0].Params.Get("metricKeys"); got != "bugs,new_coverage" {
+		t.Errorf("got metricKeys %s", got)
+	}
+}
+
+func TestPagination(t *testing.T) {
+	server := sonartest.NewServer()
+	defer server.Close()
+	var issues []sonartest.Issue
+	for i := 0; i < 1234; i++ {
+		issues = append(issues, sonartest.Issue{Key: fmt.Sprint(i)})
+	}
+	server.Issues("octocat:hello-world", issues...)
+
+	found, err := newClient(server).Issues(context.Background(), url.Values{"componentKeys": {"octocat:hello-world"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != len(issues) || found[1233].Key != "1233" {
+		t.Errorf("got %d issues, want %d", len(found), len(issues))
+	}
+	if n := server.Calls("api/issues/search"); n != 3 {
+		t.Errorf("got %d pages, want 3", n)
+	}
+}
+
+func TestError(t *testing.T) {
+	server := sonartest.NewServer()
+	defer server.Close()
+	server.Handle("api/projects/create", sonartest.Error(http.StatusBadRequest, "Could not create Project, key already exists: octocat:hello-world"))
+
+	err := newClient(server).CreateProject(context.Background(), "octocat:hello-world", "Hello World")
+	want := "POST api/projects/create: 400 Bad Request: Could not create Project, key already exists: octocat:hello-world"
+	if err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name      string
+		post      bool
+		responses []sonartest.Response
+		calls     int
+		err       bool
+	}{
+		{
+			name:      "rate limited",
+			responses: []sonartest.Response{sonartest.Error(http.StatusTooManyRequests), sonartest.Text("")},
+			calls:     2,
+		},
+		{
+			name:      "server errors",
+			responses: []sonartest.Response{sonartest.Error(http.StatusBadGateway), sonartest.Error(http.StatusServiceUnavailable), sonartest.Text("")},
+			calls:     3,
+		},
+		{
+			name:      "retries exhausted",
+			responses: []sonartest.Response{sonartest.Error(http.StatusInternalServerError)},
+			calls:     4,
+			err:       true,
+		},
+		{
+			name:      "client error",
+			responses: []sonartest.Response{sonartest.Error(http.StatusForbidden, "Insufficient privileges"), sonartest.Text("")},
+			calls:     1,
+			err:       true,
+		},
+		{
+			name:      "rate limited post",
+			post:      true,
+			responses: []sonartest.Response{sonartest.Error(http.StatusTooManyRequests), sonartest.Text("")},
+			calls:     2,
+		},
+		{
+			name:      "server error post",
+			post:      true,
+			responses: []sonartest.Response{sonartest.Error(http.StatusInternalServerError), sonartest.Text("")},
+			calls:     1,
+			err:       true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := sonartest.NewServer()
+			defer server.Close()
+			server.Handle("api/test", test.responses...)
+			c := newClient(server)
+
+			var err error
+			if test.post {
+				err = c.Post(context.Background(), "api/test", url.Values{}, nil)
+			} else {
+				err = c.Get(context.Background(), "api/test", nil, new(string))
+			}
+			if (err != nil) != test.err {
+				t.Errorf("got error %v, want error %v", err, test.err)
+			}
+			if n := server.Calls("api/test"); n != test.calls {
+				t.Errorf("got %d calls, want %d", n, test.calls)
+			}
+		})
+	}
+}
+
+func TestRetryCancelled(t *testing.T) {
+	server := sonartest.NewServer()
+	defer server.Close()
+	server.Handle("api/test", sonartest.Error(http.StatusServiceUnavailable))
+	c := newClient(server)
+	c.Backoff = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := c.Get(ctx, "api/test", nil, new(string)); err != context.DeadlineExceeded {
+		t.Errorf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+}
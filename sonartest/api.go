@@ -0,0 +1,152 @@
+package sonartest
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+type (
+	// Condition is a quality gate condition.
+	Condition struct {
+		Status         string `json:"status"`
+		MetricKey      string `json:"metricKey"`
+		Comparator     string `json:"comparator"`
+		ErrorThreshold string `json:"errorThreshold"`
+		ActualValue    string `json:"actualValue"`
+	}
+
+	// Issue is an issue returned by api/issues/search.
+	Issue struct {
+		Key       string `json:"key"`
+		Rule      string `json:"rule"`
+		Severity  string `json:"severity"`
+		Component string `json:"component"`
+		Project   string `json:"project"`
+		Line      int    `json:"line,omitempty"`
+		Message   string `json:"message"`
+		Type      string `json:"type"`
+		Status    string `json:"status"`
+	}
+)
+
+// SystemStatus scripts the states of api/system/status, such as STARTING
+// then UP.
+func (s *Server) SystemStatus(states ...string) {
+	responses := make([]Response, len(states))
+	for i, state := range states {
+		responses[i] = JSON(map[string]string{"id": "sonartest", "version": "9.9.0.65466", "status": state})
+	}
+	s.Handle("api/system/status", responses...)
+}
+
+// Task scripts the states of the background task id, such as PENDING,
+// IN_PROGRESS then SUCCESS. The analysis analysisID is set once the task
+// succeeded.
+func (s *Server) Task(id, analysisID string, states ...string) {
+	responses := make([]Response, len(states))
+	for i, state := range states {
+		task := map[string]interface{}{
+			"id":                id,
+			"type":              "REPORT",
+			"status":            state,
+			"submittedAt":       "2024-01-01T12:00:00+0000",
+			"hasScannerContext": true,
+		}
+		switch state {
+		case "SUCCESS":
+			task["analysisId"] = analysisID
+		case "FAILED":
+			task["errorMessage"] = "Failed to process the analysis report"
+		}
+		responses[i] = JSON(map[string]interface{}{"task": task})
+	}
+	s.HandleQuery("api/ce/task", "id", id, responses...)
+}
+
+// QualityGate sets the quality gate status of the analysis analysisID.
+func (s *Server) QualityGate(analysisID, status string, conditions ...Condition) {
+	if conditions == nil {
+		conditions = []Condition{}
+	}
+	s.HandleQuery("api/qualitygates/project_status", "analysisId", analysisID, JSON(map[string]interface{}{
+		"projectStatus": map[string]interface{}{
+			"status":            status,
+			"conditions":        conditions,
+			"ignoredConditions": false,
+		},
+	}))
+}
+
+// Measures sets the measures of component, by metric key. Measures of the
+// new code period are returned as such.
+func (s *Server) Measures(component string, measures map[string]string) {
+	list := []map[string]interface{}{}
+	for metric, value := range measures {
+		m := map[string]interface{}{"metric": metric}
+		if strings.HasPrefix(metric, "new_") {
+			m["period"] = map[string]interface{}{"index": 1, "value": value, "bestValue": false}
+		} else {
+			m["value"] = value
+		}
+		list = append(list, m)
+	}
+	s.HandleQuery("api/measures/component", "component", component, JSON(map[string]interface{}{
+		"component": map[string]interface{}{
+			"key":       component,
+			"name":      component,
+			"qualifier": "TRK",
+			"measures":  list,
+		},
+	}))
+}
+
+// Issues sets the issues of the project, returned by pages according to
+// the p and ps parameters.
+func (s *Server) Issues(project string, issues ...Issue) {
+	s.HandleFunc("api/issues/search", func(w http.ResponseWriter, r *http.Request) {
+		found := issues
+		if !contains(r.Form, project, "componentKeys", "components", "projects") {
+			found = nil
+		}
+		page, size := param(r, "p", 1), param(r, "ps", 100)
+		from, to := (page-1)*size, page*size
+		if from > len(found) {
+			from = len(found)
+		}
+		if to > len(found) {
+			to = len(found)
+		}
+		write(w, JSON(map[string]interface{}{
+			"total":  len(found),
+			"p":      page,
+			"ps":     size,
+			"paging": map[string]int{"pageIndex": page, "pageSize": size, "total": len(found)},
+			"issues": append([]Issue{}, found[from:to]...),
+		}))
+	})
+}
+
+// param returns the positive integer parameter name of the request, def
+// when missing or invalid.
+func param(r *http.Request, name string, def int) int {
+	n, err := strconv.Atoi(r.Form.Get(name))
+	if err != nil || n < 1 {
+		return def
+	}
+	return n
+}
+
+// contains reports whether one of the comma separated lists of the params
+// contains value.
+func contains(form url.Values, value string, params ...string) bool {
+	for _, name := range params {
+		for _, v := range strings.Split(form.Get(name), ",") {
+			if v == value {
+				return true
+			}
+		}
+	}
+	return false
+}
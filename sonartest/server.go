@@ -0,0 +1,197 @@
+// Package sonartest provides an in-process fake SonarQube server for tests.
+package sonartest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Response is a recorded Web API response.
+type Response struct {
+	Status int
+	Body   string
+}
+
+// JSON returns a 200 response with v encoded as JSON.
+func JSON(v interface{}) Response {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return Response{Status: http.StatusOK, Body: string(data)}
+}
+
+// Text returns a 200 plain text response.
+func Text(s string) Response {
+	return Response{Status: http.StatusOK, Body: s}
+}
+
+// Error returns an error response with the messages in the errors[].msg
+// format of the Web API, or an empty body without any message.
+func Error(status int, msgs ...string) Response {
+	if len(msgs) == 0 {
+		return Response{Status: status}
+	}
+	var res struct {
+		Errors []map[string]string `json:"errors"`
+	}
+	for _, msg := range msgs {
+		res.Errors = append(res.Errors, map[string]string{"msg": msg})
+	}
+	r := JSON(res)
+	r.Status = status
+	return r
+}
+
+// Request is a request received by the server.
+type Request struct {
+	Method string
+	Path   string
+	Params url.Values
+}
+
+// Server is a fake SonarQube server. Each endpoint replies with a script of
+// recorded responses, the last one being repeated once the others are used.
+// An empty script replies with a 500 error.
+type Server struct {
+	*httptest.Server
+
+	// Token is the only token accepted by the server when not empty.
+	Token string
+
+	mu       sync.Mutex
+	rules    map[string][]*rule
+	requests []Request
+}
+
+// rule answers the requests to a path whose param equals value, any
+// request when param is empty.
+type rule struct {
+	param     string
+	value     string
+	responses []Response
+	handler   http.HandlerFunc
+}
+
+// NewServer starts a SonarQube 9.9 Developer Edition server, up and
+// without any project.
+func NewServer() *Server {
+	s := &Server{rules: map[string][]*rule{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+
+	s.Handle("api/system/status", JSON(map[string]string{"id": "sonartest", "version": "9.9.0.65466", "status": "UP"}))
+	s.Handle("api/server/version", Text("9.9.0.65466"))
+	s.Handle("api/navigation/global", JSON(map[string]interface{}{"edition": "developer", "branchesEnabled": true}))
+	s.Handle("api/authentication/validate", JSON(map[string]bool{"valid": true}))
+	return s
+}
+
+// Handle scripts the responses of path.
+func (s *Server) Handle(path string, responses ...Response) {
+	s.HandleQuery(path, "", "", responses...)
+}
+
+// HandleQuery scripts the responses of path when the param of the request
+// equals value.
+func (s *Server) HandleQuery(path, param, value string, responses ...Response) {
+	s.add(path, &rule{param: param, value: value, responses: responses})
+}
+
+// HandleFunc serves path with f.
+func (s *Server) HandleFunc(path string, f http.HandlerFunc) {
+	s.add(path, &rule{handler: f})
+}
+
+func (s *Server) add(path string, r *rule) {
+	path = "/" + strings.TrimPrefix(path, "/")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rules := s.rules[path][:0:0]
+	for _, old := range s.rules[path] {
+		if old.param != r.param || old.value != r.value {
+			rules = append(rules, old)
+		}
+	}
+	s.rules[path] = append(rules, r)
+}
+
+// Requests returns the requests received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Calls returns how many requests to path were received.
+func (s *Server) Calls(path string) int {
+	path = "/" + strings.TrimPrefix(path, "/")
+	n := 0
+	for _, r := range s.Requests() {
+		if r.Path == path {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		write(w, Error(http.StatusBadRequest, err.Error()))
+		return
+	}
+
+	match, res := s.next(r)
+	if match != nil && match.handler != nil {
+		match.handler(w, r)
+		return
+	}
+	write(w, res)
+}
+
+// next records the request and returns its rule, with the next scripted
+// response of the rule when it has no handler.
+func (s *Server) next(r *http.Request) (*rule, Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, Params: r.Form})
+
+	// an unauthorized request must not consume a scripted response
+	if token, _, _ := r.BasicAuth(); s.Token != "" && token != s.Token {
+		return nil, Error(http.StatusUnauthorized)
+	}
+	var match *rule
+	for _, rule := range s.rules[r.URL.Path] {
+		if rule.param == "" && match == nil || rule.param != "" && r.Form.Get(rule.param) == rule.value {
+			match = rule
+		}
+	}
+	switch {
+	case match == nil:
+		return nil, Error(http.StatusNotFound, fmt.Sprintf("Unknown url : %s", r.URL.Path))
+	case match.handler != nil:
+		return match, Response{}
+	case len(match.responses) == 0:
+		return match, Error(http.StatusInternalServerError, fmt.Sprintf("No scripted response for %s", r.URL.Path))
+	}
+	res := match.responses[0]
+	if len(match.responses) > 1 {
+		match.responses = match.responses[1:]
+	}
+	return match, res
+}
+
+func write(w http.ResponseWriter, res Response) {
+	if strings.HasPrefix(res.Body, "{") || strings.HasPrefix(res.Body, "[") {
+		w.Header().Set("Content-Type", "application/json")
+	} else {
+		w.Header().Set("Content-Type", "text/plain")
+	}
+	w.WriteHeader(res.Status)
+	fmt.Fprint(w, res.Body)
+}
@@ -0,0 +1,142 @@
+package sonartest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func get(t *testing.T, s *Server, path string, v interface{}) int {
+	req, err := http.NewRequest("GET", s.URL+"/"+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("token", "")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if v != nil && resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestTask(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.Task("AX1", "A1", "PENDING", "IN_PROGRESS", "SUCCESS")
+	s.Task("AX2", "", "FAILED")
+
+	want := []string{"PENDING", "IN_PROGRESS", "SUCCESS", "SUCCESS"}
+	for i, status := range want {
+		var res struct {
+			Task struct {
+				Status     string `json:"status"`
+				AnalysisID string `json:"analysisId"`
+			} `json:"task"`
+		}
+		get(t, s, "api/ce/task?id=AX1", &res)
+		if res.Task.Status != status {
+			t.Errorf("poll %d: got status %s, want %s", i, res.Task.Status, status)
+		}
+		if status == "SUCCESS" && res.Task.AnalysisID != "A1" {
+			t.Errorf("poll %d: got analysis %q, want A1", i, res.Task.AnalysisID)
+		}
+	}
+	if code := get(t, s, "api/ce/task?id=AX3", nil); code != http.StatusNotFound {
+		t.Errorf("got %d for an unknown task, want 404", code)
+	}
+	if n := s.Calls("api/ce/task"); n != 5 {
+		t.Errorf("got %d calls, want 5", n)
+	}
+}
+
+func TestToken(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	if code := get(t, s, "api/system/status", nil); code != http.StatusOK {
+		t.Errorf("got %d without token, want 200", code)
+	}
+	s.Token = "other"
+	if code := get(t, s, "api/system/status", nil); code != http.StatusUnauthorized {
+		t.Errorf("got %d with another token, want 401", code)
+	}
+
+	// a rejected poll does not advance the task
+	s.Task("AX1", "A1", "PENDING", "SUCCESS")
+	if code := get(t, s, "api/ce/task?id=AX1", nil); code != http.StatusUnauthorized {
+		t.Errorf("got %d with another token, want 401", code)
+	}
+	s.Token = "token"
+	var res struct {
+		Task struct {
+			Status string `json:"status"`
+		} `json:"task"`
+	}
+	get(t, s, "api/ce/task?id=AX1", &res)
+	if res.Task.Status != "PENDING" {
+		t.Errorf("got status %s after a rejected poll, want PENDING", res.Task.Status)
+	}
+	if n := s.Calls("api/ce/task"); n != 2 {
+		t.Errorf("got %d calls, want 2", n)
+	}
+}
+
+func TestEmptyScript(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.SystemStatus()
+	s.Task("AX1", "A1")
+
+	for _, path := range []string{"api/system/status", "api/ce/task?id=AX1", "api/system/status"} {
+		if code := get(t, s, path, nil); code != http.StatusInternalServerError {
+			t.Errorf("got %d for %s without response, want 500", code, path)
+		}
+	}
+	if code := get(t, s, "api/server/version", nil); code != http.StatusOK {
+		t.Errorf("got %d after an empty script, want 200", code)
+	}
+}
+
+func TestIssues(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	var issues []Issue
+	for i := 0; i < 5; i++ {
+		issues = append(issues, Issue{Key: fmt.Sprint(i), Project: "p"})
+	}
+	s.Issues("p", issues...)
+
+	tests := []struct {
+		query string
+		keys  []string
+		total int
+	}{
+		{"componentKeys=p&ps=2", []string{"0", "1"}, 5},
+		{"componentKeys=p&ps=2&p=3", []string{"4"}, 5},
+		{"componentKeys=p&ps=2&p=4", nil, 5},
+		{"components=q", nil, 0},
+	}
+	for _, test := range tests {
+		var res struct {
+			Paging struct {
+				Total int `json:"total"`
+			} `json:"paging"`
+			Issues []Issue `json:"issues"`
+		}
+		get(t, s, "api/issues/search?"+test.query, &res)
+		var keys []string
+		for _, issue := range res.Issues {
+			keys = append(keys, issue.Key)
+		}
+		if fmt.Sprint(keys) != fmt.Sprint(test.keys) || res.Paging.Total != test.total {
+			t.Errorf("%s: got %v of %d, want %v of %d", test.query, keys, res.Paging.Total, test.keys, test.total)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aosapps/drone-sonar-plugin/scm"
+)
+
+// statusContext names the commit status, so that branch protection rules
+// can require it.
+const statusContext = "sonarqube/quality-gate"
+
+// setStatus sets the commit status of the analysed commit.
+func (p Plugin) setStatus(ctx context.Context, state scm.State, description, url string) error {
+	client, err := scm.New(p.Config.ScmProvider, p.Config.ScmServer, p.Config.ScmToken, p.Config.Repo)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("==> Setting commit status %s: %s\n", statusContext, state)
+	return client.Status(ctx, p.Config.Commit, scm.Status{
+		State:       state,
+		Context:     statusContext,
+		Description: description,
+		URL:         url,
+	})
+}
+
+// statusTimeout bounds the failed status set once the run context is done.
+const statusTimeout = 10 * time.Second
+
+// failStatus marks the commit status as failed when the analysis could not
+// complete, and returns err.
+func (p Plugin) failStatus(ctx context.Context, err error) error {
+	err = runError(ctx, err)
+	if p.Config.CommitStatus {
+		description := "Analysis failed"
+		switch {
+		case errors.Is(err, errCancelled):
+			description = "Analysis cancelled"
+		case errors.Is(err, errTimedOut):
+			description = "Analysis timed out"
+		}
+		// the run context may be done already
+		statusCtx, cancel := context.WithTimeout(context.Background(), statusTimeout)
+		defer cancel()
+		if statusErr := p.setStatus(statusCtx, scm.Failure, description, p.Config.Host); statusErr != nil {
+			fmt.Printf("==> Unable to set commit status: %v\n", statusErr)
+		}
+	}
+	return err
+}
+
+// gateStatus returns the commit status of a quality gate.
+func gateStatus(gate string) (scm.State, string) {
+	switch gate {
+	case "ERROR":
+		return scm.Failure, "Quality gate failed"
+	case "NONE":
+		return scm.Success, "No quality gate"
+	}
+	return scm.Success, "Quality gate passed"
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type (
+	// testExecutionsXML is the SonarQube Generic Execution report.
+	testExecutionsXML struct {
+		XMLName xml.Name      `xml:"testExecutions"`
+		Version int           `xml:"version,attr"`
+		Files   []testFileXML `xml:"file"`
+	}
+
+	testFileXML struct {
+		Path  string        `xml:"path,attr"`
+		Cases []testCaseXML `xml:"testCase"`
+	}
+
+	testCaseXML struct {
+		Name     string          `xml:"name,attr"`
+		Duration int64           `xml:"duration,attr"`
+		Skipped  *testMessageXML `xml:"skipped,omitempty"`
+		Failure  *testMessageXML `xml:"failure,omitempty"`
+	}
+
+	testMessageXML struct {
+		Message string `xml:"message,attr"`
+		Output  string `xml:",chardata"`
+	}
+
+	// testEvent is an event of the go test -json stream.
+	testEvent struct {
+		Action  string
+		Package string
+		Test    string
+		Elapsed float64
+		Output  string
+	}
+
+	// testResult is the outcome of a top-level test function.
+	testResult struct {
+		Package string
+		Name    string
+		Action  string
+		Elapsed float64
+		Output  []string
+	}
+)
+
+// goTests converts the go test -json streams of Config.GoTests to
+// goTestsReport.
+func (p Plugin) goTests() error {
+	streams, err := expandPaths(p.Config.GoTests)
+	if err != nil {
+		return err
+	}
+	mods, err := findModules(".")
+	if err != nil {
+		return err
+	}
+	return convertTests(streams, mods, goTestsReport)
+}
+
+// convertTests converts go test -json streams to a Generic Execution report.
+// Subtests are reported through their top-level test function.
+func convertTests(streams []string, mods modules, output string) error {
+	var results []*testResult
+	for _, stream := range streams {
+		r, err := readTestEvents(stream)
+		if err != nil {
+			return err
+		}
+		results = append(results, r...)
+	}
+
+	files := map[string]*testFileXML{}
+	funcs := map[string]map[string]string{}
+	for _, r := range results {
+		if _, ok := funcs[r.Package]; !ok {
+			funcs[r.Package] = testFuncs(mods, r.Package)
+		}
+		file, ok := funcs[r.Package][r.Name]
+		if !ok {
+			fmt.Printf("==> Skipping test %s of %s: file not found\n", r.Name, r.Package)
+			continue
+		}
+		if files[file] == nil {
+			files[file] = &testFileXML{Path: filepath.ToSlash(file)}
+		}
+		files[file].Cases = append(files[file].Cases, r.testCase())
+	}
+
+	report := testExecutionsXML{Version: 1}
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		report.Files = append(report.Files, *files[path])
+	}
+	return writeXML(output, report)
+}
+
+// readTestEvents reads the results of the top-level tests of a stream.
+func readTestEvents(path string) ([]*testResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var results []*testResult
+	running := map[string]*testResult{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "{") {
+			// build output printed before the JSON stream
+			continue
+		}
+		var e testEvent
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		if e.Test == "" {
+			continue
+		}
+
+		name := strings.SplitN(e.Test, "/", 2)[0]
+		key := e.Package + "." + name
+		r := running[key]
+		if r == nil {
+			r = &testResult{Package: e.Package, Name: name}
+			running[key] = r
+			results = append(results, r)
+		}
+		switch e.Action {
+		case "output":
+			r.Output = append(r.Output, e.Output)
+		case "pass", "fail", "skip":
+			if e.Test == name {
+				r.Action = e.Action
+				r.Elapsed = e.Elapsed
+			}
+		}
+	}
+	return results, scanner.Err()
+}
+
+func (r *testResult) testCase() testCaseXML {
+	tc := testCaseXML{Name: r.Name, Duration: int64(r.Elapsed * 1000)}
+	output := strings.Join(r.Output, "")
+	switch r.Action {
+	case "skip":
+		tc.Skipped = &testMessageXML{Message: "skipped", Output: output}
+	case "fail", "":
+		tc.Failure = &testMessageXML{Message: firstLine(r.Output), Output: output}
+	}
+	return tc
+}
+
+// firstLine returns the first output line reporting the test outcome.
+func firstLine(output []string) string {
+	for _, line := range output {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "=== ") && !strings.HasPrefix(line, "--- ") {
+			return line
+		}
+	}
+	return "failed"
+}
+
+// testFuncs maps the test functions of a package to their _test.go file.
+func testFuncs(mods modules, pkg string) map[string]string {
+	funcs := map[string]string{}
+	dir, ok := mods.resolve(pkg)
+	if !ok {
+		return funcs
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*_test.go"))
+	if err != nil {
+		return funcs
+	}
+
+	fset := token.NewFileSet()
+	for _, file := range files {
+		f, err := parser.ParseFile(fset, file, nil, 0)
+		if err != nil {
+			continue
+		}
+		for _, decl := range f.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil {
+				funcs[fn.Name.Name] = file
+			}
+		}
+	}
+	return funcs
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testStream is a go test -json stream of example.com/app/pkg, preceded by
+// build output.
+var testStream = strings.Join([]string{
+	"go: downloading github.com/pkg/errors v0.9.1",
+	"# example.com/app/pkg",
+	`{"Action":"start","Package":"example.com/app/pkg"}`,
+	`{"Action":"run","Package":"example.com/app/pkg","Test":"TestPass"}`,
+	`{"Action":"output","Package":"example.com/app/pkg","Test":"TestPass","Output":"=== RUN   TestPass\n"}`,
+	`{"Action":"pass","Package":"example.com/app/pkg","Test":"TestPass","Elapsed":0.01}`,
+	`{"Action":"run","Package":"example.com/app/pkg","Test":"TestSub"}`,
+	`{"Action":"run","Package":"example.com/app/pkg","Test":"TestSub/ok"}`,
+	`{"Action":"pass","Package":"example.com/app/pkg","Test":"TestSub/ok","Elapsed":0}`,
+	`{"Action":"run","Package":"example.com/app/pkg","Test":"TestSub/bad"}`,
+	`{"Action":"output","Package":"example.com/app/pkg","Test":"TestSub/bad","Output":"    pkg_test.go:12: got 1, want 2\n"}`,
+	`{"Action":"fail","Package":"example.com/app/pkg","Test":"TestSub/bad","Elapsed":0.002}`,
+	`{"Action":"fail","Package":"example.com/app/pkg","Test":"TestSub","Elapsed":0.25}`,
+	`{"Action":"run","Package":"example.com/app/pkg","Test":"TestSkip"}`,
+	`{"Action":"output","Package":"example.com/app/pkg","Test":"TestSkip","Output":"    pkg_test.go:20: needs docker\n"}`,
+	`{"Action":"skip","Package":"example.com/app/pkg","Test":"TestSkip","Elapsed":0}`,
+	`{"Action":"run","Package":"example.com/app/pkg","Test":"TestPanic"}`,
+	`{"Action":"output","Package":"example.com/app/pkg","Test":"TestPanic","Output":"panic: boom\n"}`,
+	`{"Action":"run","Package":"example.com/app/pkg","Test":"TestGone"}`,
+	`{"Action":"pass","Package":"example.com/app/pkg","Test":"TestGone","Elapsed":0.001}`,
+	`{"Action":"output","Package":"example.com/app/pkg","Output":"FAIL\texample.com/app/pkg\t0.3s\n"}`,
+	`{"Action":"fail","Package":"example.com/app/pkg","Elapsed":0.3}`,
+	"",
+}, "\n")
+
+// testPackage holds the test files of example.com/app/pkg.
+var testPackage = map[string]string{
+	"go.mod": "module example.com/app\n",
+	"pkg/pkg_test.go": `package pkg
+
+import "testing"
+
+func TestPass(t *testing.T) {}
+
+func TestSub(t *testing.T) {}
+
+type suite struct{}
+
+func (suite) TestMethod(t *testing.T) {}
+`,
+	"pkg/skip_test.go": `package pkg
+
+import "testing"
+
+func TestSkip(t *testing.T) {}
+
+func TestPanic(t *testing.T) {}
+`,
+	"pkg/broken_test.go": "package pkg\n\nfunc TestBroken( {\n",
+}
+
+func TestReadTestEvents(t *testing.T) {
+	chdir(t)
+	writeFiles(t, map[string]string{"tests.json": testStream})
+
+	got, err := readTestEvents("tests.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const pkg = "example.com/app/pkg"
+	want := []*testResult{
+		{Package: pkg, Name: "TestPass", Action: "pass", Elapsed: 0.01, Output: []string{"=== RUN   TestPass\n"}},
+		{Package: pkg, Name: "TestSub", Action: "fail", Elapsed: 0.25, Output: []string{"    pkg_test.go:12: got 1, want 2\n"}},
+		{Package: pkg, Name: "TestSkip", Action: "skip", Output: []string{"    pkg_test.go:20: needs docker\n"}},
+		{Package: pkg, Name: "TestPanic", Output: []string{"panic: boom\n"}},
+		{Package: pkg, Name: "TestGone", Action: "pass", Elapsed: 0.001},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%s\nwant\n%s", formatResults(got), formatResults(want))
+	}
+}
+
+func formatResults(results []*testResult) string {
+	var lines []string
+	for _, r := range results {
+		lines = append(lines, strings.TrimSpace(strings.Replace(strings.Join([]string{r.Package, r.Name, r.Action, strings.Join(r.Output, "")}, " "), "\n", `\n`, -1)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestReadTestEventsInvalid(t *testing.T) {
+	chdir(t)
+	writeFiles(t, map[string]string{"tests.json": "# example.com/app\n{\"Action\":\"run\",\n"})
+
+	_, err := readTestEvents("tests.json")
+	if want := "tests.json:2: unexpected end of JSON input"; err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+}
+
+func TestTestCase(t *testing.T) {
+	tests := []struct {
+		name   string
+		result testResult
+		want   testCaseXML
+	}{
+		{
+			name:   "pass",
+			result: testResult{Name: "TestPass", Action: "pass", Elapsed: 1.5},
+			want:   testCaseXML{Name: "TestPass", Duration: 1500},
+		},
+		{
+			name:   "fail",
+			result: testResult{Name: "TestFail", Action: "fail", Elapsed: 0.02, Output: []string{"=== RUN   TestFail\n", "    a_test.go:3: boom\n", "--- FAIL: TestFail\n"}},
+			want: testCaseXML{Name: "TestFail", Duration: 20, Failure: &testMessageXML{
+				Message: "a_test.go:3: boom",
+				Output:  "=== RUN   TestFail\n    a_test.go:3: boom\n--- FAIL: TestFail\n",
+			}},
+		},
+		{
+			name:   "no outcome",
+			result: testResult{Name: "TestHang", Output: []string{"=== RUN   TestHang\n"}},
+			want:   testCaseXML{Name: "TestHang", Failure: &testMessageXML{Message: "failed", Output: "=== RUN   TestHang\n"}},
+		},
+		{
+			name:   "skip",
+			result: testResult{Name: "TestSkip", Action: "skip", Output: []string{"    a_test.go:9: short mode\n"}},
+			want:   testCaseXML{Name: "TestSkip", Skipped: &testMessageXML{Message: "skipped", Output: "    a_test.go:9: short mode\n"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.result.testCase(); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestTestFuncs(t *testing.T) {
+	chdir(t)
+	writeFiles(t, testPackage)
+	mods := modules{"example.com/app": "."}
+
+	got := testFuncs(mods, "example.com/app/pkg")
+	want := map[string]string{
+		"TestPass":  filepath.Join("pkg", "pkg_test.go"),
+		"TestSub":   filepath.Join("pkg", "pkg_test.go"),
+		"TestSkip":  filepath.Join("pkg", "skip_test.go"),
+		"TestPanic": filepath.Join("pkg", "skip_test.go"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := testFuncs(mods, "example.com/other"); len(got) != 0 {
+		t.Errorf("got %v for an unresolved package, want none", got)
+	}
+}
+
+func TestConvertTests(t *testing.T) {
+	chdir(t)
+	writeFiles(t, testPackage)
+	writeFiles(t, map[string]string{
+		"tests.json": testStream,
+		"other.json": `{"Action":"pass","Package":"example.com/other","Test":"TestOther","Elapsed":0.1}`,
+	})
+	mods, err := findModules(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := filepath.Join(reportDir, "tests.xml")
+	out := captureStdout(t, func() {
+		err = convertTests([]string{"tests.json", "other.json"}, mods, output)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<testExecutions version="1">
+  <file path="pkg/pkg_test.go">
+    <testCase name="TestPass" duration="10"></testCase>
+    <testCase name="TestSub" duration="250">
+      <failure message="pkg_test.go:12: got 1, want 2">    pkg_test.go:12: got 1, want 2&#xA;</failure>
+    </testCase>
+  </file>
+  <file path="pkg/skip_test.go">
+    <testCase name="TestSkip" duration="0">
+      <skipped message="skipped">    pkg_test.go:20: needs docker&#xA;</skipped>
+    </testCase>
+    <testCase name="TestPanic" duration="0">
+      <failure message="panic: boom">panic: boom&#xA;</failure>
+    </testCase>
+  </file>
+</testExecutions>`
+	if string(got) != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	wantOut := "==> Skipping test TestGone of example.com/app/pkg: file not found\n" +
+		"==> Skipping test TestOther of example.com/other: file not found\n"
+	if out != wantOut {
+		t.Errorf("got output %q, want %q", out, wantOut)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type (
+	// SettingError is an invalid setting.
+	SettingError struct {
+		Setting string
+		Problem string
+	}
+
+	// ValidationError lists every invalid setting of a configuration.
+	ValidationError []SettingError
+)
+
+func (e ValidationError) Error() string {
+	var b strings.Builder
+	b.WriteString("invalid configuration:")
+	for _, s := range e {
+		fmt.Fprintf(&b, "\n    %s: %s", s.Setting, s.Problem)
+	}
+	return b.String()
+}
+
+// projectKeyPattern is the charset of SonarQube project keys, which need at
+// least one non-digit character.
+var projectKeyPattern = regexp.MustCompile(`^[a-zA-Z0-9_.:-]*[a-zA-Z_.:-][a-zA-Z0-9_.:-]*$`)
+
+// logLevels are the values of sonar.log.level.
+var logLevels = []string{"INFO", "DEBUG", "TRACE"}
+
+// Validate checks the settings before anything runs, and reports all the
+// invalid ones at once. The project key and sources are only checked when
+// they are passed to the scanner, and not taken from the properties, the
+// sonar-project.properties file or the build tool.
+func (c Config) Validate() error {
+	var errs ValidationError
+	invalid := func(setting, format string, a ...interface{}) {
+		errs = append(errs, SettingError{setting, fmt.Sprintf(format, a...)})
+	}
+
+	if c.Host == "" {
+		invalid("sonar_host", "missing SonarQube URL")
+	} else if u, err := url.Parse(c.Host); err != nil {
+		invalid("sonar_host", "invalid URL %q: %v", c.Host, err)
+	} else if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		invalid("sonar_host", "invalid URL %q, expected http://host or https://host", c.Host)
+	}
+
+	if strings.TrimSpace(c.Token) == "" {
+		invalid("sonar_token", "missing SonarQube token")
+	}
+
+	if n, err := strconv.Atoi(c.Timeout); err != nil || n <= 0 {
+		invalid("timeout", "invalid timeout %q, expected a number of seconds", c.Timeout)
+	}
+	for _, s := range []struct{ setting, value string }{
+		{"qualityGateTimeout", c.QualityGateTimeout},
+		{"retryDelay", c.RetryDelay},
+		{"runTimeout", c.RunTimeout},
+		{"gracePeriod", c.GracePeriod},
+	} {
+		if n, err := strconv.Atoi(s.value); err != nil || n < 0 {
+			invalid(s.setting, "invalid value %q, expected a number of seconds", s.value)
+		}
+	}
+	// polling more often would flood the server with background task calls
+	if n, err := strconv.Atoi(c.QualityGatePoll); err != nil || n < 1 {
+		invalid("qualityGatePoll", "invalid value %q, expected a number of seconds of at least 1", c.QualityGatePoll)
+	}
+	if n, err := strconv.Atoi(c.RetryAttempts); err != nil || n < 1 {
+		invalid("retryAttempts", "invalid value %q, expected a number of attempts of at least 1", c.RetryAttempts)
+	}
+
+	if !contains(logLevels, c.Level) {
+		invalid("level", "invalid log level %q, expected %s", c.Level, strings.Join(logLevels, ", "))
+	}
+
+	props, err := parseProperties(c.Properties)
+	if err != nil {
+		invalid("properties", "%v", err)
+	}
+	file := map[string]string{}
+	if c.UsingProperties {
+		// an unreadable file is reported by Exec
+		file, _ = readProjectProperties(projectPropertiesFile)
+	}
+	args := buildArgs(c, file, nil)
+	passed := func(key string) bool {
+		_, overridden := props[key]
+		return hasArg(args, key) && !overridden
+	}
+
+	if passed("sonar.projectKey") {
+		key := strings.Replace(c.Key, "/", ":", -1)
+		if key == "" {
+			invalid("DRONE_REPO", "missing project key")
+		} else if !projectKeyPattern.MatchString(key) {
+			invalid("DRONE_REPO", "invalid project key %q, expected letters, digits, '-', '_', '.' and ':' with at least one non-digit", key)
+		}
+	}
+
+	if passed("sonar.sources") {
+		for _, path := range splitList(c.Sources) {
+			if _, err := os.Stat(path); err != nil {
+				invalid("sources", "path %s does not exist", path)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// hasArg reports whether the scanner args set the property key.
+func hasArg(args []string, key string) bool {
+	for _, arg := range args {
+		if argKey(arg) == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	valid := Config{
+		Key:     "octocat/hello-world",
+		Host:    "https://sonar.example.com",
+		Token:   "0123456789abcdef",
+		Timeout: "60",
+		Level:   "INFO",
+		Sources: "src,.",
+
+		QualityGateTimeout: "300",
+		QualityGatePoll:    "5",
+		RetryAttempts:      "3",
+		RetryDelay:         "10",
+		RunTimeout:         "0",
+		GracePeriod:        "10",
+	}
+
+	tests := []struct {
+		name   string
+		config func(*Config)
+		file   string
+		want   ValidationError
+	}{
+		{
+			name:   "valid",
+			config: func(c *Config) {},
+		},
+		{
+			name: "all invalid",
+			config: func(c *Config) {
+				c.Host = ""
+				c.Token = " "
+				c.Timeout = "abc"
+				c.Level = "WARN"
+				c.Key = "octocat/hello world"
+				c.Sources = "src,lib"
+				c.QualityGateTimeout = "5m"
+				c.QualityGatePoll = ""
+				c.RetryAttempts = "0"
+				c.RetryDelay = "-1"
+				c.RunTimeout = "1h"
+				c.GracePeriod = "ten"
+			},
+			want: ValidationError{
+				{"sonar_host", "missing SonarQube URL"},
+				{"sonar_token", "missing SonarQube token"},
+				{"timeout", `invalid timeout "abc", expected a number of seconds`},
+				{"qualityGateTimeout", `invalid value "5m", expected a number of seconds`},
+				{"retryDelay", `invalid value "-1", expected a number of seconds`},
+				{"runTimeout", `invalid value "1h", expected a number of seconds`},
+				{"gracePeriod", `invalid value "ten", expected a number of seconds`},
+				{"qualityGatePoll", `invalid value "", expected a number of seconds of at least 1`},
+				{"retryAttempts", `invalid value "0", expected a number of attempts of at least 1`},
+				{"level", `invalid log level "WARN", expected INFO, DEBUG, TRACE`},
+				{"DRONE_REPO", `invalid project key "octocat:hello world", expected letters, digits, '-', '_', '.' and ':' with at least one non-digit`},
+				{"sources", "path lib does not exist"},
+			},
+		},
+		{
+			name:   "host without scheme",
+			config: func(c *Config) { c.Host = "sonar.example.com:9000" },
+			want:   ValidationError{{"sonar_host", `invalid URL "sonar.example.com:9000", expected http://host or https://host`}},
+		},
+		{
+			name:   "host with another scheme",
+			config: func(c *Config) { c.Host = "ftp://sonar.example.com" },
+			want:   ValidationError{{"sonar_host", `invalid URL "ftp://sonar.example.com", expected http://host or https://host`}},
+		},
+		{
+			name:   "negative timeout",
+			config: func(c *Config) { c.Timeout = "-1" },
+			want:   ValidationError{{"timeout", `invalid timeout "-1", expected a number of seconds`}},
+		},
+		{
+			name:   "zero poll interval",
+			config: func(c *Config) { c.QualityGatePoll = "0" },
+			want:   ValidationError{{"qualityGatePoll", `invalid value "0", expected a number of seconds of at least 1`}},
+		},
+		{
+			name:   "numeric key",
+			config: func(c *Config) { c.Key = "1234" },
+			want:   ValidationError{{"DRONE_REPO", `invalid project key "1234", expected letters, digits, '-', '_', '.' and ':' with at least one non-digit`}},
+		},
+		{
+			name:   "missing key",
+			config: func(c *Config) { c.Key = "" },
+			want:   ValidationError{{"DRONE_REPO", "missing project key"}},
+		},
+		{
+			name: "key and sources from properties",
+			config: func(c *Config) {
+				c.Key = ""
+				c.Sources = "lib"
+				c.Properties = "sonar.projectKey=hello\nsonar.sources=src"
+			},
+		},
+		{
+			name: "key and sources from the file",
+			config: func(c *Config) {
+				c.Key = ""
+				c.Sources = "lib"
+				c.UsingProperties = true
+			},
+			file: "sonar.projectKey=hello\nsonar.sources=src\n",
+		},
+		{
+			name:   "sources left to the build tool",
+			config: func(c *Config) { c.Mode = modeMaven; c.Sources = "lib" },
+		},
+		{
+			name:   "invalid properties",
+			config: func(c *Config) { c.Properties = "{" },
+			want:   ValidationError{{"properties", "invalid properties: unexpected EOF"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chdir(t)
+			if err := os.Mkdir("src", 0755); err != nil {
+				t.Fatal(err)
+			}
+			if test.file != "" {
+				if err := ioutil.WriteFile(projectPropertiesFile, []byte(test.file), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			config := valid
+			test.config(&config)
+
+			err := config.Validate()
+			if test.want == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			var got ValidationError
+			if !errors.As(err, &got) {
+				t.Fatalf("got error %v, want a ValidationError", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestExecInvalidConfig(t *testing.T) {
+	chdir(t)
+	runner := &fakeRunner{}
+	p := Plugin{Config: testConfig(t), Runner: runner}
+	p.Config.Timeout = "abc"
+	p.Config.Level = "debug"
+
+	err := p.Exec()
+	want := `invalid configuration:
+    timeout: invalid timeout "abc", expected a number of seconds
+    level: invalid log level "debug", expected INFO, DEBUG, TRACE`
+	if err == nil || err.Error() != want {
+		t.Errorf("got error %v, want\n%s", err, want)
+	}
+	if len(runner.calls) != 0 {
+		t.Errorf("got %d commands run, want none", len(runner.calls))
+	}
+}